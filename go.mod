@@ -2,7 +2,12 @@ module github.com/y0ug/mcpkit
 
 go 1.23.3
 
-require golang.org/x/exp/jsonrpc2 v0.0.0-20250128182459-e0ece0dbea4c
+require (
+	github.com/coder/websocket v1.8.14
+	go.opentelemetry.io/otel v1.35.0
+	go.opentelemetry.io/otel/trace v1.35.0
+	golang.org/x/exp/jsonrpc2 v0.0.0-20250128182459-e0ece0dbea4c
+)
 
 require (
 	golang.org/x/exp/event v0.0.0-20220217172124-1812c5b45e43 // indirect
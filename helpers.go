@@ -3,6 +3,8 @@ package mcpkit
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"sync"
 )
 
 func FetchAll[T any](
@@ -20,7 +22,8 @@ func FetchAll[T any](
 
 		allItems = append(allItems, items...)
 
-		if nextCursor == nil {
+		// Some servers send an empty cursor instead of omitting it
+		if nextCursor == nil || *nextCursor == "" {
 			break
 		}
 
@@ -29,3 +32,138 @@ func FetchAll[T any](
 
 	return allItems, nil
 }
+
+// FetchAllConcurrent fetches every page like FetchAll, issuing up to
+// parallelism fetches at once when the cursors can be predicted.
+//
+// MCP cursors are opaque, so prefetching only works with servers whose
+// cursors are integer offsets or page numbers, such as "50" then "100".
+// Pages are fetched one after the other until two cursors in a row are
+// integers with a constant step. The following cursors are then guessed
+// and fetched ahead, although the server never issued them. A guess is
+// only kept once the page before it returned the same cursor: a wrong
+// guess, or an error for a cursor that was wrongly guessed, falls back to
+// the cursor the server returned. Servers with other cursors are
+// detected and fetched sequentially, like FetchAll. Items are returned in
+// page order.
+func FetchAllConcurrent[T any](
+	ctx context.Context,
+	fetch func(ctx context.Context, cursor *string) ([]T, *string, error),
+	parallelism int,
+) ([]T, error) {
+	if parallelism <= 1 {
+		return FetchAll(ctx, fetch)
+	}
+
+	var allItems []T
+	var cursor *string
+	// prev is the offset of the cursor before the current one, when it
+	// is one
+	var prev *int64
+
+	for {
+		if offset, ok := cursorOffset(cursor); ok && prev != nil && offset > *prev {
+			items, next, err := fetchAhead(ctx, fetch, offset, offset-*prev, parallelism)
+			allItems = append(allItems, items...)
+			if err != nil {
+				return nil, fmt.Errorf("fetch failed: %w", err)
+			}
+			if next == nil {
+				return allItems, nil
+			}
+			// Mispredicted, carry on from the cursor the server gave
+			cursor, prev = next, nil
+			continue
+		}
+
+		items, nextCursor, err := fetch(ctx, cursor)
+		if err != nil {
+			return nil, fmt.Errorf("fetch failed: %w", err)
+		}
+		allItems = append(allItems, items...)
+
+		if nextCursor == nil || *nextCursor == "" {
+			return allItems, nil
+		}
+		if offset, ok := cursorOffset(cursor); ok {
+			prev = &offset
+		} else {
+			prev = nil
+		}
+		cursor = nextCursor
+	}
+}
+
+// cursorOffset returns the integer a cursor holds, if it is one written
+// the way strconv formats it
+func cursorOffset(cursor *string) (int64, bool) {
+	if cursor == nil {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(*cursor, 10, 64)
+	if err != nil || strconv.FormatInt(n, 10) != *cursor {
+		return 0, false
+	}
+	return n, true
+}
+
+type fetchedPage[T any] struct {
+	items []T
+	next  *string
+	err   error
+}
+
+// fetchAhead fetches the pages at offset, offset+step and so on,
+// parallelism at a time, until the last page. It returns the items of the
+// pages whose cursor was guessed right. next is nil when the last page was
+// reached, otherwise it is the cursor returned where the guess was wrong.
+func fetchAhead[T any](
+	ctx context.Context,
+	fetch func(ctx context.Context, cursor *string) ([]T, *string, error),
+	offset, step int64,
+	parallelism int,
+) ([]T, *string, error) {
+	var allItems []T
+	for {
+		pages := make([]fetchedPage[T], parallelism)
+		ctxs := make([]context.Context, parallelism)
+		cancels := make([]context.CancelFunc, parallelism)
+		for i := range pages {
+			ctxs[i], cancels[i] = context.WithCancel(ctx)
+		}
+		var wg sync.WaitGroup
+		for i := range pages {
+			cursor := strconv.FormatInt(offset+int64(i)*step, 10)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				page := &pages[i]
+				page.items, page.next, page.err = fetch(ctxs[i], &cursor)
+				// The pages after the last one are not needed
+				if page.err == nil && (page.next == nil || *page.next == "") {
+					for _, cancel := range cancels[i+1:] {
+						cancel()
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		for _, cancel := range cancels {
+			cancel()
+		}
+
+		for i, page := range pages {
+			if page.err != nil {
+				return allItems, nil, page.err
+			}
+			allItems = append(allItems, page.items...)
+			if page.next == nil || *page.next == "" {
+				return allItems, nil, nil
+			}
+			if *page.next != strconv.FormatInt(offset+int64(i+1)*step, 10) {
+				return allItems, page.next, nil
+			}
+		}
+		offset += int64(parallelism) * step
+	}
+}
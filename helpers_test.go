@@ -0,0 +1,224 @@
+package mcpkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFetchAll(t *testing.T) {
+	str := func(s string) *string { return &s }
+	type page struct {
+		items []int
+		next  *string
+	}
+	tests := []struct {
+		name  string
+		pages map[string]page
+		want  []int
+	}{
+		{
+			name:  "nil cursor ends",
+			pages: map[string]page{"": {items: []int{1, 2}}},
+			want:  []int{1, 2},
+		},
+		{
+			name: "empty cursor ends",
+			pages: map[string]page{
+				"":  {items: []int{1}, next: str("2")},
+				"2": {items: []int{2}, next: str("")},
+			},
+			want: []int{1, 2},
+		},
+		{
+			name: "several pages",
+			pages: map[string]page{
+				"":  {items: []int{1}, next: str("b")},
+				"b": {items: nil, next: str("c")},
+				"c": {items: []int{2, 3}},
+			},
+			want: []int{1, 2, 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			fetch := func(ctx context.Context, cursor *string) ([]int, *string, error) {
+				calls++
+				if calls > len(tt.pages) {
+					return nil, nil, errors.New("fetched past the last page")
+				}
+				key := ""
+				if cursor != nil {
+					key = *cursor
+				}
+				p, ok := tt.pages[key]
+				if !ok {
+					return nil, nil, errors.New("unknown cursor " + key)
+				}
+				return p.items, p.next, nil
+			}
+
+			got, err := FetchAll(context.Background(), fetch)
+			if err != nil {
+				t.Fatalf("FetchAll: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("FetchAll = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// pagedServer serves items 0 to total-1, size per page. Its cursors come
+// from cursor, and any other cursor fails like an unknown one would.
+type pagedServer struct {
+	total, size int
+	cursor      func(page int) string
+
+	mu      sync.Mutex
+	fetched []string
+	active  int
+	// maxActive is the most fetches seen running at once
+	maxActive int
+}
+
+func (s *pagedServer) fetch(ctx context.Context, cursor *string) ([]int, *string, error) {
+	key := ""
+	if cursor != nil {
+		key = *cursor
+	}
+	s.mu.Lock()
+	s.fetched = append(s.fetched, key)
+	s.active++
+	s.maxActive = max(s.maxActive, s.active)
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.active--
+		s.mu.Unlock()
+	}()
+
+	pages := (s.total + s.size - 1) / s.size
+	page := -1
+	for p := 0; p < pages; p++ {
+		if (p == 0 && key == "") || (p > 0 && key == s.cursor(p)) {
+			page = p
+			break
+		}
+	}
+	if page < 0 {
+		return nil, nil, fmt.Errorf("invalid cursor %q", key)
+	}
+	// Let the fetches run together
+	time.Sleep(time.Millisecond)
+
+	var items []int
+	for i := page * s.size; i < min((page+1)*s.size, s.total); i++ {
+		items = append(items, i)
+	}
+	if page == pages-1 {
+		return items, nil, nil
+	}
+	next := s.cursor(page + 1)
+	return items, &next, nil
+}
+
+func (s *pagedServer) issued(cursor string) bool {
+	pages := (s.total + s.size - 1) / s.size
+	for p := 1; p < pages; p++ {
+		if s.cursor(p) == cursor {
+			return true
+		}
+	}
+	return cursor == ""
+}
+
+func TestFetchAllConcurrent(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor func(page int) string
+		// prefetch is whether cursors the server never issued are fetched
+		prefetch bool
+	}{
+		{
+			name:     "integer offsets",
+			cursor:   func(page int) string { return strconv.Itoa(page * 5) },
+			prefetch: true,
+		},
+		{
+			name:     "page numbers",
+			cursor:   func(page int) string { return strconv.Itoa(page) },
+			prefetch: true,
+		},
+		{
+			name:   "opaque",
+			cursor: func(page int) string { return fmt.Sprintf("page-%d", page) },
+		},
+		{
+			name:   "padded integers",
+			cursor: func(page int) string { return fmt.Sprintf("%04d", page*5) },
+		},
+		{
+			// Looks like offsets for a while, then the guesses are wrong
+			// and fail on the server
+			name: "irregular integers",
+			cursor: func(page int) string {
+				return strconv.Itoa(page*page*10 + page)
+			},
+			prefetch: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &pagedServer{total: 100, size: 5, cursor: tt.cursor}
+			got, err := FetchAllConcurrent(context.Background(), s.fetch, 4)
+			if err != nil {
+				t.Fatalf("FetchAllConcurrent: %v", err)
+			}
+			want := make([]int, s.total)
+			for i := range want {
+				want[i] = i
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("FetchAllConcurrent = %v, want %v", got, want)
+			}
+
+			guessed := 0
+			for _, cursor := range s.fetched {
+				if !s.issued(cursor) {
+					guessed++
+				}
+			}
+			if !tt.prefetch {
+				if guessed > 0 || s.maxActive > 1 {
+					t.Errorf("fetched %v, %d at once, want only issued cursors one at a time", s.fetched, s.maxActive)
+				}
+				if len(s.fetched) != 20 {
+					t.Errorf("fetched %d pages, want 20", len(s.fetched))
+				}
+			} else if s.maxActive < 2 {
+				t.Errorf("fetched %v one at a time, want them prefetched", s.fetched)
+			}
+		})
+	}
+}
+
+func TestFetchAllConcurrentError(t *testing.T) {
+	s := &pagedServer{total: 100, size: 5, cursor: func(page int) string { return strconv.Itoa(page * 5) }}
+	failing := errors.New("server failed")
+	fetch := func(ctx context.Context, cursor *string) ([]int, *string, error) {
+		if cursor != nil && *cursor == "50" {
+			return nil, nil, failing
+		}
+		return s.fetch(ctx, cursor)
+	}
+	if _, err := FetchAllConcurrent(context.Background(), fetch, 4); !errors.Is(err, failing) {
+		t.Errorf("FetchAllConcurrent error = %v, want %v", err, failing)
+	}
+}
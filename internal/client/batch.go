@@ -0,0 +1,254 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/jsonrpc2"
+)
+
+// BatchRequest is one request of a batch sent with CallBatch. Notification
+// requests get no response.
+type BatchRequest struct {
+	Method       string
+	Params       interface{}
+	Notification bool
+}
+
+// BatchResponse is the response to the BatchRequest at the same index.
+// Error is an *RPCError when the server answered with an error. Both fields
+// are empty for notifications.
+type BatchResponse struct {
+	Result json.RawMessage
+	Error  error
+}
+
+// batchingFramer wraps the framer of a connection so that the requests
+// issued between begin and flush are written as one JSON-RPC batch
+type batchingFramer struct {
+	base jsonrpc2.Framer
+
+	mu     sync.Mutex
+	writer *batchingWriter
+}
+
+func newBatchingFramer(base jsonrpc2.Framer) *batchingFramer {
+	return &batchingFramer{base: base}
+}
+
+func (f *batchingFramer) Reader(r io.Reader) jsonrpc2.Reader {
+	return f.base.Reader(r)
+}
+
+func (f *batchingFramer) Writer(w io.Writer) jsonrpc2.Writer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.writer = &batchingWriter{base: f.base.Writer(w)}
+	return f.writer
+}
+
+// batchingWriter holds back the requests written while batching
+type batchingWriter struct {
+	base jsonrpc2.Writer
+
+	mu       sync.Mutex
+	batching bool
+	pending  []jsonrpc2.Message
+}
+
+func (w *batchingWriter) Write(ctx context.Context, msg jsonrpc2.Message) (int64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// Responses to the server requests are not part of the batch
+	if _, ok := msg.(*jsonrpc2.Request); ok && w.batching {
+		w.pending = append(w.pending, msg)
+		return 0, nil
+	}
+	n, err := w.base.Write(ctx, msg)
+	if err != nil {
+		// The message did not reach the server, which a retry relies on
+		err = &sendError{err: err}
+	}
+	return n, err
+}
+
+func (w *batchingWriter) begin() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.batching = true
+	w.pending = nil
+}
+
+// flush writes the requests held back since begin as a single batch
+func (w *batchingWriter) flush(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	msgs := w.pending
+	w.batching = false
+	w.pending = nil
+	if len(msgs) == 0 {
+		return nil
+	}
+	_, err := writeBatch(ctx, w.base, msgs)
+	return err
+}
+
+// CallBatch sends reqs to the server as a single JSON-RPC batch and waits
+// for all the responses, returned in the order of reqs. The error is only
+// set when the batch could not be sent or awaited, the errors of the
+// individual requests are in the responses. The batch is written as one
+// JSON array when the framer supports it, as separate messages otherwise.
+// The whole batch is bounded by WithBatchTimeout, or the request timeout.
+func (c *client) CallBatch(
+	ctx context.Context,
+	reqs []BatchRequest,
+) (_ []BatchResponse, err error) {
+	if err := c.checkInitialized(ctx); err != nil {
+		return nil, err
+	}
+	if len(reqs) == 0 {
+		return nil, nil
+	}
+	if c.ctx.Err() != nil {
+		return nil, c.closedErr()
+	}
+	if err := c.ensureStarted(); err != nil {
+		return nil, err
+	}
+	done, err := c.drain.track()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	if c.metrics != nil {
+		c.metrics.RequestStarted("batch", "")
+	}
+	c.stats.started()
+	start := time.Now()
+	defer func() {
+		d := time.Since(start)
+		c.stats.finished("batch", d, err)
+		if c.metrics != nil {
+			c.metrics.RequestFinished("batch", "", d, err)
+		}
+	}()
+
+	callerCtx := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(c.ctx, cancel)
+	defer stop()
+	timeout := c.requestTimeout
+	if c.batchTimeout > 0 {
+		timeout = c.batchTimeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	ctx, span := c.tracer.Start(ctx, "mcp.client.batch",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("rpc.system", "jsonrpc")),
+		trace.WithAttributes(attribute.Int("mcp.batch.size", len(reqs))),
+	)
+	defer span.End()
+	defer c.handler.spans.add(span)()
+
+	calls, err := c.sendBatch(ctx, reqs)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("batch failed: %w", err)
+	}
+
+	resps := make([]BatchResponse, len(reqs))
+	for i, ac := range calls {
+		if ac == nil {
+			continue
+		}
+		var raw json.RawMessage
+		err := ac.Await(ctx, &raw)
+		if err == nil {
+			resps[i].Result = raw
+			continue
+		}
+		if rpcErr, ok := toRPCError(err); ok {
+			resps[i].Error = rpcErr
+			continue
+		}
+
+		// The batch itself failed, cancel what is still pending
+		if timeout > 0 && callerCtx.Err() == nil &&
+			errors.Is(err, context.DeadlineExceeded) {
+			err = &ErrRequestTimeout{Method: "batch", Timeout: timeout}
+		} else if callerCtx.Err() == nil && c.ctx.Err() != nil {
+			err = c.closedErr()
+		}
+		for _, ac := range calls[i:] {
+			if ac != nil && c.ctx.Err() == nil {
+				c.cancelRequest(callerCtx, ac.ID(), "batch cancelled")
+			}
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("batch failed: %w", err)
+	}
+	return resps, nil
+}
+
+// sendBatch issues the calls and notifications of reqs and writes them as
+// one batch. The calls are nil for notifications.
+func (c *client) sendBatch(ctx context.Context, reqs []BatchRequest) ([]*jsonrpc2.AsyncCall, error) {
+	// Marshal the params first so that an invalid one fails the batch
+	// before anything is sent
+	params := make([]interface{}, len(reqs))
+	for i, req := range reqs {
+		if req.Params == nil {
+			continue
+		}
+		data, err := json.Marshal(req.Params)
+		if err != nil {
+			return nil, fmt.Errorf("invalid params for %s: %w", req.Method, err)
+		}
+		params[i] = json.RawMessage(data)
+	}
+
+	c.batcher.mu.Lock()
+	w := c.batcher.writer
+	c.batcher.mu.Unlock()
+	if w == nil {
+		return nil, errors.New("connection not ready")
+	}
+
+	if err := c.queue.acquire(ctx, PriorityDefault); err != nil {
+		return nil, err
+	}
+	defer c.queue.release()
+
+	calls := make([]*jsonrpc2.AsyncCall, len(reqs))
+	w.begin()
+	for i, req := range reqs {
+		if req.Notification {
+			if err := c.conn.Notify(ctx, req.Method, params[i]); err != nil {
+				_ = w.flush(ctx)
+				return nil, fmt.Errorf("%s: %w", req.Method, err)
+			}
+			continue
+		}
+		calls[i] = c.conn.Call(ctx, req.Method, params[i])
+	}
+	if err := w.flush(ctx); err != nil {
+		return nil, err
+	}
+	return calls, nil
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"golang.org/x/exp/jsonrpc2"
+)
+
+func TestBatchFrameRoundTrip(t *testing.T) {
+	call, err := jsonrpc2.NewCall(jsonrpc2.Int64ID(1), "tools/list", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notif, err := jsonrpc2.NewNotification("notifications/roots/list_changed", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ping, err := jsonrpc2.NewCall(jsonrpc2.StringID("p"), "ping", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := []jsonrpc2.Message{call, notif, ping}
+
+	for _, tt := range []struct {
+		name   string
+		framer jsonrpc2.Framer
+	}{
+		{"line", NewLineRawFramer()},
+		{"header", NewHeaderFramer()},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			var buf bytes.Buffer
+			if _, err := writeBatch(ctx, tt.framer.Writer(&buf), msgs); err != nil {
+				t.Fatalf("writeBatch: %v", err)
+			}
+			if !bytes.Contains(buf.Bytes(), []byte(`[{"jsonrpc":"2.0"`)) {
+				t.Fatalf("batch written as %q, want a JSON array", buf.String())
+			}
+
+			r := tt.framer.Reader(&buf)
+			for i, want := range msgs {
+				msg, _, err := r.Read(ctx)
+				if err != nil {
+					t.Fatalf("Read %d: %v", i, err)
+				}
+				got, ok := msg.(*jsonrpc2.Request)
+				if !ok {
+					t.Fatalf("message %d is a %T", i, msg)
+				}
+				want := want.(*jsonrpc2.Request)
+				if got.Method != want.Method || got.ID != want.ID {
+					t.Errorf("message %d = %s %v, want %s %v", i, got.Method, got.ID, want.Method, want.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestCallBatchMixed(t *testing.T) {
+	s := newFakeServer()
+	s.handle("tools/list", func(context.Context, json.RawMessage) (interface{}, error) {
+		return ListToolsResult{Tools: []Tool{{Name: "echo"}}}, nil
+	})
+	c := newInitializedClient(t, s)
+
+	resps, err := c.CallBatch(testContext(t), []BatchRequest{
+		{Method: "tools/list"},
+		{Method: "notifications/roots/list_changed", Notification: true},
+		{Method: "prompts/unknown"},
+		{Method: "ping"},
+	})
+	if err != nil {
+		t.Fatalf("CallBatch: %v", err)
+	}
+	if len(resps) != 4 {
+		t.Fatalf("got %d responses, want 4", len(resps))
+	}
+
+	var tools ListToolsResult
+	if err := json.Unmarshal(resps[0].Result, &tools); err != nil || len(tools.Tools) != 1 {
+		t.Errorf("tools/list response = %s, %v", resps[0].Result, resps[0].Error)
+	}
+	if resps[1].Result != nil || resps[1].Error != nil {
+		t.Errorf("notification response = %+v, want none", resps[1])
+	}
+	var rpcErr *RPCError
+	if !errors.As(resps[2].Error, &rpcErr) || rpcErr.Code != CodeMethodNotFound {
+		t.Errorf("unknown method response error = %v, want method not found", resps[2].Error)
+	}
+	if string(resps[3].Result) != `{}` || resps[3].Error != nil {
+		t.Errorf("ping response = %s, %v", resps[3].Result, resps[3].Error)
+	}
+
+	// The notification reached the server along with the requests
+	s.waitRequest(t, "notifications/roots/list_changed", 1)
+}
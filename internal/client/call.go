@@ -0,0 +1,248 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/jsonrpc2"
+)
+
+const tracerName = "github.com/y0ug/mcpkit"
+
+// CallOption configures a single request sent to the server
+type CallOption func(*callOptions)
+
+type callOptions struct {
+	timeout  time.Duration
+	priority int
+}
+
+// CallWithTimeout overrides the client request timeout for this call
+func CallWithTimeout(d time.Duration) CallOption {
+	return func(o *callOptions) {
+		o.timeout = d
+	}
+}
+
+// CallWithPriority lets the request be sent ahead of queued requests with a
+// lower priority. Requests default to PriorityDefault.
+func CallWithPriority(priority int) CallOption {
+	return func(o *callOptions) {
+		o.priority = priority
+	}
+}
+
+// ErrRequestTimeout is returned when the server did not answer a request
+// within the configured timeout. It wraps context.DeadlineExceeded, but
+// unlike the caller's own deadline it is only returned for the client
+// request timeout.
+type ErrRequestTimeout struct {
+	Method  string
+	Timeout time.Duration
+}
+
+func (e *ErrRequestTimeout) Error() string {
+	return fmt.Sprintf("request %s timed out after %s", e.Method, e.Timeout)
+}
+
+func (e *ErrRequestTimeout) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// call sends a request to the server, waits for the response and decodes it
+// into result. The exchange is recorded as a span named after the method.
+func (c *client) call(
+	ctx context.Context,
+	method string,
+	params interface{},
+	result interface{},
+	attrs ...attribute.KeyValue,
+) error {
+	return c.callWith(ctx, method, params, result, nil, attrs...)
+}
+
+// callWith sends a request like call, retried with WithRetry
+func (c *client) callWith(
+	ctx context.Context,
+	method string,
+	params interface{},
+	result interface{},
+	opts []CallOption,
+	attrs ...attribute.KeyValue,
+) error {
+	return c.withRetries(ctx, method, func() error {
+		return c.callOnce(ctx, method, params, result, opts, attrs...)
+	})
+}
+
+// callOnce makes a single attempt at a request
+func (c *client) callOnce(
+	ctx context.Context,
+	method string,
+	params interface{},
+	result interface{},
+	opts []CallOption,
+	attrs ...attribute.KeyValue,
+) (err error) {
+	co := callOptions{timeout: c.timeoutFor(method)}
+	for _, opt := range opts {
+		opt(&co)
+	}
+
+	if c.ctx.Err() != nil {
+		return c.closedErr()
+	}
+	if err := c.ensureStarted(); err != nil {
+		return err
+	}
+	done, err := c.drain.track()
+	if err != nil {
+		return err
+	}
+	defer done()
+	var tool string
+	if c.metrics != nil {
+		tool = toolName(params)
+		c.metrics.RequestStarted(method, tool)
+	}
+	c.stats.started()
+	start := time.Now()
+	defer func() {
+		d := time.Since(start)
+		c.stats.finished(method, d, err)
+		if c.metrics != nil {
+			c.metrics.RequestFinished(method, tool, d, err)
+		}
+	}()
+
+	// Stop waiting as soon as the client is closed, the connection does not
+	// fail pending calls when the server goes away
+	callerCtx := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(c.ctx, cancel)
+	defer stop()
+	if co.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, co.timeout)
+		defer cancel()
+	}
+
+	ctx, span := c.tracer.Start(ctx, "mcp.client."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("rpc.system", "jsonrpc")),
+		trace.WithAttributes(attribute.String("rpc.method", method)),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(c.serverAttrs()...),
+	)
+	defer span.End()
+	defer c.handler.spans.add(span)()
+
+	// Only a valid span context has something to propagate, which keeps
+	// the requests untouched without tracing
+	if span.SpanContext().IsValid() {
+		traced, err := withTraceContext(ctx, params)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return err
+		}
+		params = traced
+		span.SetAttributes(attribute.Int("mcp.request.size", len(traced)))
+	}
+
+	if err := c.queue.acquire(ctx, co.priority); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	ac := c.conn.Call(ctx, method, params)
+	c.queue.release()
+
+	var raw json.RawMessage
+	if err := ac.Await(ctx, &raw); err != nil {
+		if co.timeout > 0 && callerCtx.Err() == nil &&
+			errors.Is(err, context.DeadlineExceeded) {
+			err = &ErrRequestTimeout{Method: method, Timeout: co.timeout}
+			c.cancelRequest(callerCtx, ac.ID(), "request timed out")
+		} else if callerCtx.Err() == nil && c.ctx.Err() != nil {
+			err = &interruptedError{method: method, err: c.closedErr()}
+		} else if callerCtx.Err() != nil && method != "initialize" {
+			// The spec forbids cancelling initialize
+			c.cancelRequest(callerCtx, ac.ID(), "request cancelled")
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	span.SetAttributes(attribute.Int("mcp.result.size", len(raw)))
+
+	if result == nil || len(raw) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(raw, result); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
+
+// notify sends a notification to the server
+func (c *client) notify(ctx context.Context, method string, params interface{}) (err error) {
+	if err := c.ensureStarted(); err != nil {
+		return err
+	}
+	if c.metrics != nil {
+		c.metrics.RequestStarted(method, "")
+		start := time.Now()
+		defer func() {
+			c.metrics.RequestFinished(method, "", time.Since(start), err)
+		}()
+	}
+	return c.conn.Notify(ctx, method, params)
+}
+
+// timeoutFor returns the timeout applying to method, zero meaning none
+func (c *client) timeoutFor(method string) time.Duration {
+	category, _, _ := strings.Cut(method, "/")
+	if d, ok := c.methodTimeouts[category]; ok {
+		return d
+	}
+	return c.requestTimeout
+}
+
+// cancelRequest tells the server to stop processing a request whose result
+// will not be used anymore
+func (c *client) cancelRequest(ctx context.Context, id jsonrpc2.ID, reason string) {
+	requestID, ok := id.Raw().(int64)
+	if !ok {
+		return
+	}
+	params := CancelledNotificationParams{
+		RequestId: RequestId(requestID),
+		Reason:    &reason,
+	}
+	ctx = context.WithoutCancel(ctx)
+	if err := c.queue.acquire(ctx, PriorityHigh); err != nil {
+		return
+	}
+	defer c.queue.release()
+	if err := c.notify(ctx, "notifications/cancelled", params); err != nil {
+		c.logger.Debug("failed to cancel request", "id", requestID, "error", err)
+	}
+}
+
+func cursorAttr(cursor *string) attribute.KeyValue {
+	if cursor == nil {
+		return attribute.String("mcp.cursor", "")
+	}
+	return attribute.String("mcp.cursor", *cursor)
+}
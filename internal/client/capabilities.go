@@ -0,0 +1,145 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// MarshalJSON implements json.Marshaler. The generated struct drops empty
+// maps, but an empty sampling object is how a client advertises sampling, so
+// a non-nil map is always encoded.
+func (j ClientCapabilities) MarshalJSON() ([]byte, error) {
+	var wire struct {
+		Experimental ClientCapabilitiesExperimental `json:"experimental,omitempty"`
+		Roots        *ClientCapabilitiesRoots       `json:"roots,omitempty"`
+		Sampling     *ClientCapabilitiesSampling    `json:"sampling,omitempty"`
+	}
+	wire.Experimental = j.Experimental
+	wire.Roots = j.Roots
+	if j.Sampling != nil {
+		wire.Sampling = &j.Sampling
+	}
+	return json.Marshal(wire)
+}
+
+// mergeCapabilities returns the capabilities of cur, falling back to the
+// ones of prev that cur does not set
+func mergeCapabilities(prev, cur ServerCapabilities) ServerCapabilities {
+	merged := cur
+	if merged.Prompts == nil {
+		merged.Prompts = prev.Prompts
+	}
+	if merged.Resources == nil {
+		merged.Resources = prev.Resources
+	}
+	if merged.Tools == nil {
+		merged.Tools = prev.Tools
+	}
+	if prev.Logging != nil {
+		merged.Logging = make(ServerCapabilitiesLogging, len(prev.Logging)+len(cur.Logging))
+		for k, v := range prev.Logging {
+			merged.Logging[k] = v
+		}
+		for k, v := range cur.Logging {
+			merged.Logging[k] = v
+		}
+	}
+	if prev.Experimental != nil {
+		merged.Experimental = make(ServerCapabilitiesExperimental, len(prev.Experimental)+len(cur.Experimental))
+		for k, v := range prev.Experimental {
+			merged.Experimental[k] = v
+		}
+		for k, v := range cur.Experimental {
+			merged.Experimental[k] = v
+		}
+	}
+	return merged
+}
+
+// ErrCapabilityNotSupported is returned, without sending the request, for
+// requests needing a capability the server did not advertise. It matches
+// ErrMethodNotFound, which the server would have answered.
+type ErrCapabilityNotSupported struct {
+	Capability string
+}
+
+func (e *ErrCapabilityNotSupported) Error() string {
+	return fmt.Sprintf("server does not support %s", e.Capability)
+}
+
+func (e *ErrCapabilityNotSupported) Unwrap() error {
+	return ErrMethodNotFound
+}
+
+// SupportsTools reports whether the server offers tools
+func (s *ServerInfo) SupportsTools() bool {
+	return s != nil && s.Capabilities.Tools != nil
+}
+
+// SupportsResources reports whether the server offers resources, which it
+// may do without subscriptions nor list change notifications, see
+// ResourceFeatures
+func (s *ServerInfo) SupportsResources() bool {
+	return s != nil && s.Capabilities.Resources != nil
+}
+
+// ResourceFeatures reports whether the server lets clients subscribe to
+// resources and notifies changes to their list
+func (s *ServerInfo) ResourceFeatures() (subscribe, listChanged bool) {
+	if !s.SupportsResources() {
+		return false, false
+	}
+	r := s.Capabilities.Resources
+	return r.Subscribe != nil && *r.Subscribe, r.ListChanged != nil && *r.ListChanged
+}
+
+// SupportsPrompts reports whether the server offers prompts
+func (s *ServerInfo) SupportsPrompts() bool {
+	return s != nil && s.Capabilities.Prompts != nil
+}
+
+// SupportsLogging reports whether the server sends log messages
+func (s *ServerInfo) SupportsLogging() bool {
+	return s != nil && s.Capabilities.Logging != nil
+}
+
+// Supports reports whether the server advertised capability, such as
+// "tools", "resources", "prompts", "logging" or an experimental one
+func (s *ServerInfo) Supports(capability string) bool {
+	switch capability {
+	case "tools":
+		return s.SupportsTools()
+	case "resources":
+		return s.SupportsResources()
+	case "prompts":
+		return s.SupportsPrompts()
+	case "logging":
+		return s.SupportsLogging()
+	}
+	if s == nil {
+		return false
+	}
+	_, ok := s.Capabilities.Experimental[capability]
+	return ok
+}
+
+// Supports reports whether the server advertised capability in initialize,
+// false until then
+func (c *client) Supports(capability string) bool {
+	if !c.initialized.Load() {
+		return false
+	}
+	return c.serverInfo.Load().Supports(capability)
+}
+
+// checkCapability is checkInitialized for the requests needing capability
+func (c *client) checkCapability(ctx context.Context, capability string) error {
+	if err := c.checkInitialized(ctx); err != nil {
+		return err
+	}
+	if !c.serverInfo.Load().Supports(capability) {
+		return &ErrCapabilityNotSupported{Capability: capability}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestServerInfoSupports(t *testing.T) {
+	type supports struct {
+		tools, resources, subscribe, listChanged, prompts, logging, experimental bool
+	}
+	tests := []struct {
+		name string
+		caps string
+		want supports
+	}{
+		{name: "none", caps: `{}`},
+		{name: "tools", caps: `{"tools":{}}`, want: supports{tools: true}},
+		{name: "resources", caps: `{"resources":{}}`, want: supports{resources: true}},
+		{
+			name: "resources subscribe",
+			caps: `{"resources":{"subscribe":true,"listChanged":false}}`,
+			want: supports{resources: true, subscribe: true},
+		},
+		{
+			name: "resources list changed",
+			caps: `{"resources":{"listChanged":true}}`,
+			want: supports{resources: true, listChanged: true},
+		},
+		{name: "prompts", caps: `{"prompts":{"listChanged":true}}`, want: supports{prompts: true}},
+		{name: "logging", caps: `{"logging":{}}`, want: supports{logging: true}},
+		{name: "experimental", caps: `{"experimental":{"search":{}}}`, want: supports{experimental: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := &ServerInfo{}
+			if err := json.Unmarshal([]byte(tt.caps), &info.Capabilities); err != nil {
+				t.Fatal(err)
+			}
+			subscribe, listChanged := info.ResourceFeatures()
+			got := supports{
+				tools:        info.SupportsTools(),
+				resources:    info.SupportsResources(),
+				subscribe:    subscribe,
+				listChanged:  listChanged,
+				prompts:      info.SupportsPrompts(),
+				logging:      info.SupportsLogging(),
+				experimental: info.Supports("search"),
+			}
+			if got != tt.want {
+				t.Errorf("supports = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	var nilInfo *ServerInfo
+	if nilInfo.SupportsTools() || nilInfo.Supports("search") {
+		t.Error("a nil ServerInfo supports capabilities")
+	}
+	if subscribe, listChanged := nilInfo.ResourceFeatures(); subscribe || listChanged {
+		t.Error("a nil ServerInfo has resource features")
+	}
+}
+
+func TestUnsupportedCapability(t *testing.T) {
+	s := newFakeServer()
+	s.caps = map[string]interface{}{"tools": map[string]interface{}{}}
+	c := newInitializedClient(t, s)
+
+	_, _, err := c.ListPrompts(testContext(t), nil)
+	var unsupported *ErrCapabilityNotSupported
+	if !errors.As(err, &unsupported) || unsupported.Capability != "prompts" {
+		t.Fatalf("ListPrompts = %v, want *ErrCapabilityNotSupported for prompts", err)
+	}
+	if !errors.Is(err, ErrMethodNotFound) {
+		t.Errorf("errors.Is(%v, ErrMethodNotFound) = false", err)
+	}
+	if reqs := s.requests("prompts/list"); len(reqs) != 0 {
+		t.Error("prompts/list was sent to the server")
+	}
+}
+
+func TestReinitializeCapabilities(t *testing.T) {
+	s := newFakeServer()
+	s.caps = map[string]interface{}{"tools": map[string]interface{}{}}
+	c := newTestClient(t, s)
+	ctx := testContext(t)
+	info, err := c.Initialize(ctx)
+	if err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+
+	s.mu.Lock()
+	s.caps = map[string]interface{}{"prompts": map[string]interface{}{}}
+	s.mu.Unlock()
+
+	// Read the capabilities while they are replaced, for the race detector
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			c.Supports("prompts")
+			c.serverAttrs()
+			_ = info.Capabilities.Prompts
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		if err := c.ReinitializeCapabilities(ctx); err != nil {
+			t.Fatalf("ReinitializeCapabilities: %v", err)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	if !c.Supports("prompts") || !c.Supports("tools") {
+		t.Error("the capabilities were not merged")
+	}
+	if info.SupportsPrompts() {
+		t.Error("the ServerInfo returned by Initialize was changed")
+	}
+	current, err := c.Initialize(ctx)
+	if err != nil || !current.SupportsPrompts() || !current.SupportsTools() {
+		t.Errorf("Initialize = %+v, %v, want the merged capabilities", current, err)
+	}
+}
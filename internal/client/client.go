@@ -2,38 +2,153 @@ package client
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"os"
 	"os/exec"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/exp/jsonrpc2"
 )
 
 // Client defines the interface for MCP client operations
 type Client interface {
-	// Initialize sends the initialize request to the server and stores the capabilities
+	// Initialize sends the initialize request to the server and stores the
+	// capabilities. Once it succeeded, it returns the stored ServerInfo
+	// without a new handshake.
 	Initialize(ctx context.Context) (*ServerInfo, error)
 
+	// ReinitializeCapabilities runs the initialize handshake again to
+	// refresh the server capabilities, for servers reloaded in place
+	ReinitializeCapabilities(ctx context.Context) error
+
 	// Ping sends a ping request to check if the server is alive
 	Ping(ctx context.Context) error
 
 	// ListTools requests the list of available tools from the server
 	ListTools(ctx context.Context, cursor *string) ([]Tool, *string, error)
 
+	// Tool returns the tool called name from the tools cached by ListTools,
+	// with WithToolCache, or by CallToolValidated
+	Tool(name string) (*Tool, bool)
+
 	// ListResources requests the list of available resources from the server
 	ListResources(ctx context.Context, cursor *string) ([]Resource, *string, error)
 
+	// ListResourceTemplates requests the list of resource templates from the server
+	ListResourceTemplates(ctx context.Context, cursor *string) ([]ResourceTemplate, *string, error)
+
 	// ReadResource reads a specific resource from the server
-	ReadResource(ctx context.Context, uri string) (*[]interface{}, error)
+	ReadResource(ctx context.Context, uri string) ([]ResourceContent, error)
+
+	// ReadResourceRaw reads a specific resource from the server without
+	// decoding its contents
+	ReadResourceRaw(ctx context.Context, uri string) (*[]interface{}, error)
+
+	// Subscribe asks the server to notify the client when the resource at uri
+	// changes, calling fn with the URI of the updated resource
+	Subscribe(ctx context.Context, uri string, fn func(uri string)) error
+
+	// Unsubscribe cancels a subscription made with Subscribe
+	Unsubscribe(ctx context.Context, uri string) error
+
+	// ListPrompts requests the list of available prompts from the server
+	ListPrompts(ctx context.Context, cursor *string) ([]Prompt, *string, error)
+
+	// GetPrompt retrieves a prompt, filling its template with args
+	GetPrompt(ctx context.Context, name string, args map[string]string) (*GetPromptResult, error)
+
+	// Complete asks the server for the values completing an argument of a
+	// prompt or a resource template
+	Complete(
+		ctx context.Context,
+		ref CompletionRef,
+		argName, argValue string,
+	) (*CompletionResult, error)
+
+	// SetLevel asks the server to send log messages at the given level and above
+	SetLevel(ctx context.Context, level LoggingLevel) error
+
+	// OnLogMessage registers a callback for the log messages sent by the
+	// server and returns a function that removes it. Callbacks are run on
+	// the connection goroutine and must not block.
+	OnLogMessage(fn func(LoggingMessageNotification)) func()
+
+	// OnNotification registers a callback for the notifications of method,
+	// or of any method with "*". The returned function unregisters it.
+	OnNotification(method string, fn NotificationHandler) func()
+
+	// SetSamplingHandler registers the handler answering the sampling requests
+	// of the server. Without one they fail with a method not found error.
+	SetSamplingHandler(fn SamplingHandler)
+
+	// SetRoots sets the roots listed to the server, which must be file://
+	// URIs, and notifies the server when they change
+	SetRoots(roots []Root) error
+
+	// SetRootsListHandler makes the client answer roots/list with the roots
+	// returned by fn instead of the ones given to SetRoots
+	SetRootsListHandler(fn func(ctx context.Context) ([]Root, error))
+
+	// CallTool executes a specific tool with given parameters. args can be a
+	// map or any value that marshals to a JSON object, such as a struct
+	CallTool(
+		ctx context.Context,
+		name string,
+		args interface{},
+		opts ...CallOption,
+	) (*CallToolResult, error)
+
+	// CallToolValidated checks args against the input schema of the tool
+	// before calling it, failing with *ArgValidationError on a mismatch
+	CallToolValidated(
+		ctx context.Context,
+		name string,
+		args interface{},
+		opts ...CallOption,
+	) (*CallToolResult, error)
+
+	// StderrTail returns the last lines the server wrote to stderr
+	StderrTail() []string
 
-	// CallTool executes a specific tool with given parameters
-	CallTool(ctx context.Context, name string, args map[string]interface{}) (*CallToolResult, error)
+	// Healthy reports whether the client is initialized and, with
+	// WithKeepalive, whether the server answers the keepalive pings
+	Healthy() bool
+
+	// LastPong returns when the server last answered a keepalive ping
+	LastPong() time.Time
+
+	// Stats returns the traffic of the client with the server
+	Stats() ConnectionStats
+
+	// Err returns a channel receiving the error the client failed with,
+	// closed once the client is closed
+	Err() <-chan error
+
+	// CallBatch sends several requests at once as a JSON-RPC batch
+	CallBatch(ctx context.Context, reqs []BatchRequest) ([]BatchResponse, error)
+
+	// Supports reports whether the server advertised capability, such as
+	// "tools", "resources", "prompts", "logging" or an experimental one
+	Supports(capability string) bool
 
 	// Close shuts down the MCP client and server
 	Close() error
+
+	// DrainAndClose waits for the requests in flight, refusing new ones,
+	// until ctx is done, then closes the client
+	DrainAndClose(ctx context.Context) error
 }
 
 type client struct {
@@ -41,13 +156,79 @@ type client struct {
 	cancelFn context.CancelFunc
 	ctx      context.Context
 	logger   *slog.Logger
-	doneChan chan error
+	// doneChan is closed once the server process exited, waitErr then
+	// holds the result of Wait
+	doneChan chan struct{}
+	waitErr  error
+	tracer   trace.Tracer
+	handler  *dispatcher
+	queue    sendQueue
+	batcher  *batchingFramer
+
+	// clientInfo and capabilities are sent to the server during initialize
+	clientInfo   Implementation
+	capabilities ClientCapabilities
+	// protocolVersions are the versions accepted from the server, the
+	// first one is requested
+	protocolVersions []string
+	protocolVersion  string
+
+	// requestTimeout bounds every request unless overridden per method
+	// category or per call
+	requestTimeout time.Duration
+	methodTimeouts map[string]time.Duration
+	batchTimeout   time.Duration
+	metrics        Collector
+	retryPolicy    *RetryPolicy
+
+	// stderrWriter receives the server stderr when set, stderrDone is
+	// closed once all of it has been read
+	stderrWriter io.Writer
+	stderrDone   chan struct{}
+	// stderrHandler is called with every line of the server stderr, the
+	// last ones are kept in stderrTail
+	stderrHandler    func(line string)
+	stderrTail       *stderrTail
+	stderrInErrors   int
+	structuredStderr bool
 
-	// Track initialization state
-	initialized bool
+	// initialized is set by Initialize and cleared by Close, initMu
+	// serializes the handshakes
+	initialized    atomic.Bool
+	initMu         sync.Mutex
+	autoInitialize bool
 
-	// Server capabilities received during initialization
-	ServerInfo *ServerInfo
+	keepalive keepalive
+
+	// tools caches the tools list for CallToolValidated, and ListTools
+	// with cacheTools
+	tools      toolCache
+	cacheTools bool
+
+	// stats counts the traffic with the server
+	stats connStats
+	// drain tracks the requests in flight for DrainAndClose
+	drain drain
+	// lazy starts the server on the first request with WithLazyStart
+	lazy *lazyStart
+
+	// fatal is set when the server process died, before the client closes
+	fatal atomic.Pointer[FatalServerError]
+	exit  *errNotifier
+
+	// closeOnce makes Close idempotent, as the process monitor closes the
+	// client when the server exits while users defer Close too
+	closeOnce sync.Once
+	closeErr  error
+
+	// shutdownTimeout is how long Close waits for the server to exit after
+	// closing its stdin before terminating it
+	shutdownTimeout time.Duration
+
+	// serverInfo holds the capabilities received during initialization.
+	// ReinitializeCapabilities swaps in a new one, the ServerInfo handed
+	// to callers is never changed.
+	serverInfo atomic.Pointer[ServerInfo]
 
 	cmd    *exec.Cmd
 	Stream *Stream
@@ -59,110 +240,309 @@ type Stream struct {
 	Stderr io.ReadCloser
 }
 
-func logHandler(logger *slog.Logger) jsonrpc2.HandlerFunc {
-	return func(ctx context.Context, req *jsonrpc2.Request) (interface{}, error) {
-		logger.Info("Request received",
-			"method", req.Method,
-			"id", req.ID.Raw(),
-			"params", string(req.Params))
-		return nil, jsonrpc2.ErrNotHandled
+// New creates a new MCP client and starts the language server
+func New(
+	ctxParent context.Context,
+	logger *slog.Logger,
+	serverCmd string,
+	args []string,
+	opts ...Option,
+) (Client, error) {
+	o := newOptions(opts)
+	if o.restartPolicy != nil {
+		return newResilient(ctxParent, logger, serverCmd, args, *o.restartPolicy, o)
 	}
+	return newClient(ctxParent, logger, serverCmd, args, o)
 }
 
-type FatalServerError struct {
-	Msg string
-}
+// NewAndInitialize creates a client and runs the initialize handshake,
+// shutting the server down again if the handshake fails
+func NewAndInitialize(
+	ctx context.Context,
+	logger *slog.Logger,
+	serverCmd string,
+	args []string,
+	opts ...Option,
+) (Client, *ServerInfo, error) {
+	c, err := New(ctx, logger, serverCmd, args, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
 
-func (e *FatalServerError) Error() string {
-	return e.Msg
+	info, err := c.Initialize(ctx)
+	if err != nil {
+		if cerr := c.Close(); cerr != nil {
+			logger.Error("failed to close client", "error", cerr)
+		}
+		return nil, nil, err
+	}
+	return c, info, nil
 }
 
-// New creates a new MCP client and starts the language server
-func New(
+func newClient(
 	ctxParent context.Context,
 	logger *slog.Logger,
 	serverCmd string,
-	args ...string,
-) (Client, error) {
+	args []string,
+	o options,
+) (*client, error) {
+	if o.lazyStart {
+		if _, err := exec.LookPath(serverCmd); err != nil {
+			return nil, fmt.Errorf("failed to start MCP server: %w", err)
+		}
+		client := newConnClient(ctxParent, logger, o)
+		client.lazy = &lazyStart{start: func() error {
+			return client.startProcess(serverCmd, args, o)
+		}}
+		return client, nil
+	}
+
+	client := newConnClient(ctxParent, logger, o)
+	if err := client.startProcess(serverCmd, args, o); err != nil {
+		client.cancelFn()
+		return nil, err
+	}
+	return client, nil
+}
+
+// startProcess starts the server command and connects to it
+func (c *client) startProcess(serverCmd string, args []string, o options) error {
 	cmd := exec.Command(serverCmd, args...)
+	cmd.Env = o.environ()
+	cmd.Dir = o.workingDir
 
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create stdin pipe: %w", err)
+		return fmt.Errorf("failed to create stdin pipe: %w", err)
 	}
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+		return fmt.Errorf("failed to create stdout pipe: %w", err)
 	}
 
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create stderr pipe: %w", err)
+		return fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+	for _, customize := range o.cmdCustomizers {
+		customize(cmd)
 	}
 	if err := cmd.Start(); err != nil {
-		return nil, fmt.Errorf("failed to start MCP server: %w", err)
+		return fmt.Errorf("failed to start MCP server: %w", err)
 	}
 
-	// Channel to check if the process is running
-	doneChan := make(chan error, 1)
+	c.cmd = cmd
 	go func() {
-		doneChan <- cmd.Wait()
+		c.waitErr = cmd.Wait()
+		close(c.doneChan)
 	}()
+	// Start error monitoring in a goroutine
+	go c.monitorErrors(stderr)
 
+	dialer := &StdioStream{
+		reader: stdout,
+		writer: stdin,
+	}
+	if err := c.dial(dialer, o); err != nil {
+		cmd.Process.Kill()
+		return err
+	}
+	return nil
+}
+
+// NewFromStream creates a client for a server already running at the other
+// end of rwc, such as a network connection or a pipe to an in-process
+// server. No process is started: Close closes rwc, and the client closes
+// once the server closes it. Options about the server process, such as
+// WithEnv or WithRestartPolicy, are ignored.
+func NewFromStream(
+	ctx context.Context,
+	logger *slog.Logger,
+	rwc io.ReadWriteCloser,
+	opts ...Option,
+) (Client, error) {
+	return NewWithTransport(ctx, logger, streamTransport{rwc}, opts...)
+}
+
+// NewFromFDs creates a client for a server process started by someone
+// else, talking to it through the file descriptors of its stdin and
+// stdout. Close closes both, telling the server to exit, but does not wait
+// for the process or kill it.
+func NewFromFDs(
+	ctx context.Context,
+	logger *slog.Logger,
+	stdinFD, stdoutFD uintptr,
+	opts ...Option,
+) (Client, error) {
+	stdin := os.NewFile(stdinFD, "mcp-server-stdin")
+	if stdin == nil {
+		return nil, fmt.Errorf("invalid stdin file descriptor %d", stdinFD)
+	}
+	stdout := os.NewFile(stdoutFD, "mcp-server-stdout")
+	if stdout == nil {
+		return nil, fmt.Errorf("invalid stdout file descriptor %d", stdoutFD)
+	}
+	return NewWithTransport(ctx, logger, &StdioStream{
+		reader: stdout,
+		writer: stdin,
+	}, opts...)
+}
+
+// NewWithTransport creates a client for a server already running, reached
+// through t. Like with NewFromStream, Close closes the stream returned by t
+// and options about the server process are ignored.
+func NewWithTransport(
+	ctx context.Context,
+	logger *slog.Logger,
+	t Transport,
+	opts ...Option,
+) (Client, error) {
+	o := newOptions(opts)
+	client := newConnClient(ctx, logger, o)
+	if err := client.dial(t, o); err != nil {
+		return nil, err
+	}
+	go client.monitorStream()
+	return client, nil
+}
+
+// newConnClient creates a client from the options, not connected yet
+func newConnClient(ctxParent context.Context, logger *slog.Logger, o options) *client {
 	ctx, cancel := context.WithCancel(ctxParent)
 
+	if o.dispatcher == nil {
+		o.dispatcher = newDispatcher(logger, o.tracerProvider.Tracer(tracerName))
+	}
+	if o.stderrTail == nil {
+		o.stderrTail = newStderrTail()
+	}
+
 	client := &client{
-		cmd:      cmd,
 		logger:   logger,
 		ctx:      ctx,
 		cancelFn: cancel,
-		doneChan: doneChan,
-	}
-	// Start error monitoring in a goroutine
-	go client.monitorErrors(stderr)
+		doneChan: make(chan struct{}),
+		exit:     newErrNotifier(),
+		tracer:   o.tracerProvider.Tracer(tracerName),
+		handler:  o.dispatcher,
 
-	dialer := &StdioStream{
-		reader: stdout,
-		writer: stdin,
+		clientInfo:       o.clientInfo,
+		capabilities:     o.capabilities,
+		protocolVersions: o.protocolVersions,
+		protocolVersion:  o.protocolVersion,
+
+		requestTimeout: o.requestTimeout,
+		methodTimeouts: o.methodTimeouts,
+		batchTimeout:   o.batchTimeout,
+		metrics:        o.metrics,
+		retryPolicy:    o.retryPolicy,
+		autoInitialize: o.autoInitialize,
+
+		tools:      toolCache{ttl: o.toolCacheTTL},
+		cacheTools: o.toolCache,
+
+		stderrWriter: o.stderrWriter,
+		stderrDone:   make(chan struct{}),
+
+		stderrHandler:  o.stderrHandler,
+		stderrTail:     o.stderrTail,
+		stderrInErrors: o.stderrInErrors,
+
+		structuredStderr: o.structuredStderr,
+
+		keepalive: keepalive{
+			interval:        o.keepaliveInterval,
+			timeout:         o.keepaliveTimeout,
+			maxFailures:     o.keepaliveFailures,
+			onUnhealthy:     o.onUnhealthy,
+			killOnUnhealthy: o.killOnUnhealthy,
+		},
+		shutdownTimeout: o.shutdownTimeout,
 	}
+	// The cached tools are stale once the server says so, for as long as
+	// the client is open
+	context.AfterFunc(ctx, client.handler.onNotification(
+		"notifications/tools/list_changed",
+		func(context.Context, json.RawMessage) { client.tools.invalidate() },
+	))
+	return client
+}
 
-	// HeaderFramer is the jsonrpc2.Framer options
-	// That's what MCP servers are expecting
-	debug := false
-	framer := NewLineRawFramer()
-	if debug {
-		framer = &LoggingFramer{
-			Base: framer,
+// dial connects the client to the server through dialer
+func (c *client) dial(dialer Transport, o options) error {
+	// Newline delimited JSON is what MCP stdio servers are expecting
+	framer := o.framer
+	if framer == nil {
+		framer = NewLineRawFramer()
+	}
+	if t, ok := dialer.(framedTransport); ok {
+		framer = t.framer()
+	}
+	if o.frameLogger != nil || o.wireLog {
+		logging := &LoggingFramer{
+			Base:     framer,
+			Logger:   o.frameLogger,
+			MaxBytes: o.wireLogMaxBytes,
+			Redact:   o.wireLogRedact,
 		}
+		if o.wireLog {
+			logging.Logger = c.logger
+			logging.Level = o.wireLogLevel
+		}
+		framer = logging
 	}
 
-	conn, err := jsonrpc2.Dial(
-		ctx,
-		dialer,
-		jsonrpc2.ConnectionOptions{
-			Handler: logHandler(logger),
-			Framer:  framer,
-		},
-	)
+	c.batcher = newBatchingFramer(countingFramer{base: framer, stats: &c.stats})
+
+	conn, err := jsonrpc2.Dial(c.ctx, dialer, c.handler.binder(c.batcher))
 	if err != nil {
-		cancel()
-		cmd.Process.Kill()
-		return nil, fmt.Errorf("dial error: %w", err)
+		c.cancelFn()
+		return fmt.Errorf("dial error: %w", err)
 	}
-	client.conn = conn
-	return client, nil
+	c.conn = conn
+	return nil
+}
+
+// monitorStream closes a client created with NewFromStream once the server
+// closed the stream
+func (c *client) monitorStream() {
+	err := c.conn.Wait()
+	if c.ctx.Err() != nil {
+		// Close closed the stream
+		return
+	}
+	if errors.Is(err, io.EOF) {
+		c.logger.Info("server closed the stream")
+	} else {
+		c.logger.Error("stream closed", "error", err)
+	}
+	c.Close()
 }
 
 func (c *client) monitorErrors(stderr io.ReadCloser) {
 	// Process and print stderr errors
 	go func() {
+		defer close(c.stderrDone)
 		scanner := bufio.NewScanner(stderr)
 		for scanner.Scan() {
 			errText := scanner.Text()
+			c.stderrTail.add(errText)
+			if c.stderrHandler != nil {
+				c.stderrHandler(errText)
+			}
+			if c.stderrWriter != nil {
+				if _, err := fmt.Fprintln(c.stderrWriter, errText); err != nil {
+					c.logger.Debug("failed to forward stderr", "error", err)
+				}
+				continue
+			}
 			if errText == "" {
 				continue
 			}
+			if c.structuredStderr && c.logStructured(errText) {
+				continue
+			}
 
 			c.logger.Debug("reading", "stderr", errText)
 
@@ -174,8 +554,9 @@ func (c *client) monitorErrors(stderr io.ReadCloser) {
 			}
 		}
 
-		// Check for scanner errors
-		if err := scanner.Err(); err != nil {
+		// Check for scanner errors. Wait closes the pipe once the process
+		// exited, which is not an error
+		if err := scanner.Err(); err != nil && !errors.Is(err, os.ErrClosed) {
 			c.logger.Error("error reading stderr", "error", err)
 		}
 	}()
@@ -185,83 +566,187 @@ func (c *client) monitorErrors(stderr io.ReadCloser) {
 		select {
 		case <-c.ctx.Done():
 			return
-		case err := <-c.doneChan:
-			// if c.cmd.ProcessState != nil {
-			c.logger.Error("process exited", "error", err)
-			// }
+		case <-c.doneChan:
+			if c.ctx.Err() != nil {
+				// Close is shutting the server down
+				return
+			}
+			fatal := c.newFatalServerError()
+			c.logger.Error("process exited", "error", c.waitErr,
+				"exit_code", fatal.ExitCode)
+			c.fatal.Store(fatal)
 			c.Close()
 		}
 	}
 }
 
+// ServerInfo is the result of initialize. Its ProtocolVersion is the
+// version negotiated with the server.
 type ServerInfo InitializeResult
 
-// Initialize sends the initialize request to the server and stores the capabilities
+// Initialize sends the initialize request to the server and stores the
+// capabilities. Once it succeeded, it returns the stored ServerInfo without
+// a new handshake, which servers do not expect.
 func (c *client) Initialize(ctx context.Context) (*ServerInfo, error) {
+	c.initMu.Lock()
+	defer c.initMu.Unlock()
+	if c.initialized.Load() {
+		return c.serverInfo.Load(), nil
+	}
+
+	version := c.protocolVersions[0]
+	if c.protocolVersion != "" {
+		if !slices.Contains(c.protocolVersions, c.protocolVersion) {
+			return nil, fmt.Errorf(
+				"initialize failed: protocol version %q is not supported (supported %v)",
+				c.protocolVersion, c.protocolVersions,
+			)
+		}
+		version = c.protocolVersion
+	}
+
 	method := "initialize"
 	params := InitializeRequestParams{
-		ClientInfo: Implementation{
-			Name:    "mcptest",
-			Version: "0.1.0",
-		},
-		ProtocolVersion: "2024-11-05",
-		Capabilities:    ClientCapabilities{
-			// Add capabilities as needed
-		},
+		ClientInfo:      c.clientInfo,
+		ProtocolVersion: version,
+		Capabilities:    c.capabilities,
 	}
 
 	var result InitializeResult
 	c.logger.Debug("Sending initialize request")
-	if err := c.conn.Call(ctx, method, params).Await(c.ctx, &result); err != nil {
+	if err := c.call(ctx, method, params, &result); err != nil {
+		return nil, fmt.Errorf("initialize failed: %w", err)
+	}
+	err := negotiateVersion(params.ProtocolVersion, result.ProtocolVersion, c.protocolVersions)
+	if err != nil {
 		return nil, fmt.Errorf("initialize failed: %w", err)
 	}
 
-	c.ServerInfo = (*ServerInfo)(&result)
-	c.initialized = true
+	info := (*ServerInfo)(&result)
+	c.serverInfo.Store(info)
+	c.initialized.Store(true)
 
 	c.logger.Debug("Server initialized",
-		"name", c.ServerInfo.ServerInfo.Name,
-		"version", c.ServerInfo.ServerInfo.Version)
-	if c.ServerInfo.Instructions != nil {
-		c.logger.Debug("Server instructions", "instructions", *c.ServerInfo.Instructions)
+		"name", info.ServerInfo.Name,
+		"version", info.ServerInfo.Version)
+	if info.Instructions != nil {
+		c.logger.Debug("Server instructions", "instructions", *info.Instructions)
 	}
 
-	for k, v := range c.ServerInfo.Capabilities.Logging {
+	for k, v := range info.Capabilities.Logging {
 		c.logger.Debug("Capabilities Logging", "key", k, "value", v)
 	}
 
 	// Send initialized notification
-	if err := c.conn.Notify(ctx, "notifications/initialized", nil); err != nil {
+	if err := c.notify(ctx, "notifications/initialized", nil); err != nil {
 		return nil, fmt.Errorf("failed to send initialized notification: %w", err)
 	}
-	return c.ServerInfo, nil
+	c.startKeepalive()
+	return info, nil
+}
+
+// checkInitialized returns ErrClientClosed once the client is closed, and
+// an error until Initialize succeeded, unless WithAutoInitialize lets it
+// initialize the client
+func (c *client) checkInitialized(ctx context.Context) error {
+	if c.ctx.Err() != nil {
+		return c.closedErr()
+	}
+	if c.initialized.Load() {
+		return nil
+	}
+	if !c.autoInitialize {
+		return errNotInitialized
+	}
+	_, err := c.Initialize(ctx)
+	return err
+}
+
+// ReinitializeCapabilities runs the initialize handshake again on the live
+// connection, for servers whose tools or resources changed after a hot
+// reload. The server must answer with the protocol version negotiated
+// first, or *ErrUnsupportedProtocolVersion is returned. The new capabilities
+// are merged with the previous ones, those the server no longer sends are
+// kept, and the cached tools list is dropped. The ServerInfo returned by
+// Initialize before is left as it was, Initialize returns the new one.
+func (c *client) ReinitializeCapabilities(ctx context.Context) error {
+	if err := c.checkInitialized(ctx); err != nil {
+		return err
+	}
+	c.initMu.Lock()
+	defer c.initMu.Unlock()
+	current := c.serverInfo.Load()
+	version := current.ProtocolVersion
+	params := InitializeRequestParams{
+		ClientInfo:      c.clientInfo,
+		ProtocolVersion: version,
+		Capabilities:    c.capabilities,
+	}
+
+	var result InitializeResult
+	if err := c.call(ctx, "initialize", params, &result); err != nil {
+		return fmt.Errorf("reinitialize failed: %w", err)
+	}
+	if result.ProtocolVersion != version {
+		return fmt.Errorf("reinitialize failed: %w", &ErrUnsupportedProtocolVersion{
+			Requested: version,
+			Got:       result.ProtocolVersion,
+			Supported: []string{version},
+		})
+	}
+
+	result.Capabilities = mergeCapabilities(current.Capabilities, result.Capabilities)
+	c.serverInfo.Store((*ServerInfo)(&result))
+	c.tools.invalidate()
+
+	if err := c.notify(ctx, "notifications/initialized", nil); err != nil {
+		return fmt.Errorf("failed to send initialized notification: %w", err)
+	}
+	return nil
 }
 
 // Ping sends a ping request to check if the server is alive
 func (c *client) Ping(ctx context.Context) error {
-	if !c.initialized {
-		return fmt.Errorf("client not initialized")
+	if err := c.checkInitialized(ctx); err != nil {
+		return err
 	}
-	if err := c.conn.Call(ctx, "ping", nil).Await(ctx, nil); err != nil {
+	opts := []CallOption{CallWithPriority(PriorityHigh)}
+	if err := c.callWith(ctx, "ping", nil, nil, opts); err != nil {
 		return fmt.Errorf("ping failed: %w", err)
 	}
 
 	return nil
 }
 
-// ListTools requests the list of available tools from the server
+// ListTools requests the list of available tools from the server. With
+// WithToolCache, the first page returns all the tools from the cache.
 func (c *client) ListTools(ctx context.Context, cursor *string) ([]Tool, *string, error) {
-	if !c.initialized {
-		return nil, nil, fmt.Errorf("client not initialized")
+	if c.cacheTools && (cursor == nil || *cursor == "") {
+		if err := c.checkInitialized(ctx); err != nil {
+			return nil, nil, err
+		}
+		tools, _, err := c.cachedTools(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		return slices.Clone(tools), nil, nil
+	}
+	return c.listTools(ctx, cursor)
+}
+
+// listTools requests a page of the tools from the server
+func (c *client) listTools(ctx context.Context, cursor *string) ([]Tool, *string, error) {
+	if err := c.checkInitialized(ctx); err != nil {
+		return nil, nil, err
 	}
 	params := &ListToolsRequestParams{Cursor: cursor}
 
 	var result ListToolsResult
-	if err := c.conn.Call(ctx, "tools/list", params).Await(ctx, &result); err != nil {
+	if err := c.call(ctx, "tools/list", params, &result, cursorAttr(cursor)); err != nil {
 		return nil, nil, fmt.Errorf("list tools failed: %w", err)
 	}
 
-	return result.Tools, nil, nil
+	return result.Tools, result.NextCursor, nil
 }
 
 // ListResources requests the list of available resources from the server
@@ -269,101 +754,390 @@ func (c *client) ListResources(
 	ctx context.Context,
 	cursor *string,
 ) ([]Resource, *string, error) {
-	if !c.initialized {
-		return nil, nil, fmt.Errorf("client not initialized")
+	if err := c.checkCapability(ctx, "resources"); err != nil {
+		return nil, nil, err
 	}
 	params := &ListResourcesRequestParams{Cursor: cursor}
 
 	var result ListResourcesResult
-	if err := c.conn.Call(ctx, "resources/list", params).Await(ctx, &result); err != nil {
+	if err := c.call(ctx, "resources/list", params, &result, cursorAttr(cursor)); err != nil {
 		return nil, nil, fmt.Errorf("list resources failed: %w", err)
 	}
 
 	return result.Resources, result.NextCursor, nil
 }
 
+// ListResourceTemplates requests the list of resource templates from the server
+func (c *client) ListResourceTemplates(
+	ctx context.Context,
+	cursor *string,
+) ([]ResourceTemplate, *string, error) {
+	if err := c.checkCapability(ctx, "resources"); err != nil {
+		return nil, nil, err
+	}
+	params := &ListResourceTemplatesRequestParams{Cursor: cursor}
+
+	var result ListResourceTemplatesResult
+	if err := c.call(
+		ctx, "resources/templates/list", params, &result, cursorAttr(cursor),
+	); err != nil {
+		return nil, nil, fmt.Errorf("list resource templates failed: %w", err)
+	}
+
+	return result.ResourceTemplates, result.NextCursor, nil
+}
+
 // ReadResource reads a specific resource from the server
 func (c *client) ReadResource(
 	ctx context.Context,
 	uri string,
-) (*[]interface{}, error) {
-	if !c.initialized {
-		return nil, fmt.Errorf("client not initialized")
+) ([]ResourceContent, error) {
+	var result struct {
+		Contents []ResourceContent `json:"contents"`
 	}
+	if err := c.readResource(ctx, uri, &result); err != nil {
+		return nil, err
+	}
+
+	return result.Contents, nil
+}
+
+// ReadResourceRaw reads a specific resource from the server without
+// decoding its contents
+func (c *client) ReadResourceRaw(
+	ctx context.Context,
+	uri string,
+) (*[]interface{}, error) {
 	var result ReadResourceResult
-	params := ReadResourceRequestParams{Uri: uri}
-	if err := c.conn.Call(ctx, "resources/read", params).Await(ctx, &result); err != nil {
-		return nil, fmt.Errorf("read resource failed: %w", err)
+	if err := c.readResource(ctx, uri, &result); err != nil {
+		return nil, err
 	}
 
 	return &result.Contents, nil
 }
 
+func (c *client) readResource(ctx context.Context, uri string, result interface{}) error {
+	if err := c.checkCapability(ctx, "resources"); err != nil {
+		return err
+	}
+	params := ReadResourceRequestParams{Uri: uri}
+	if err := c.call(
+		ctx, "resources/read", params, result,
+		attribute.String("mcp.resource.uri", uri),
+	); err != nil {
+		if code, ok := errorCode(err); ok && code == CodeResourceNotFound {
+			return &ErrResourceNotFound{URI: uri}
+		}
+		return fmt.Errorf("read resource failed: %w", err)
+	}
+
+	return nil
+}
+
+// Subscribe asks the server to notify the client when the resource at uri
+// changes, calling fn with the URI of the updated resource
+func (c *client) Subscribe(ctx context.Context, uri string, fn func(uri string)) error {
+	if err := c.checkCapability(ctx, "resources"); err != nil {
+		return err
+	}
+	// Register first so that an update sent right after the response is
+	// not missed
+	c.handler.subscribe(uri, fn)
+	params := SubscribeRequestParams{Uri: uri}
+	if err := c.call(
+		ctx, "resources/subscribe", params, nil,
+		attribute.String("mcp.resource.uri", uri),
+	); err != nil {
+		c.handler.unsubscribe(uri)
+		return fmt.Errorf("subscribe failed: %w", err)
+	}
+
+	return nil
+}
+
+// Unsubscribe cancels a subscription made with Subscribe
+func (c *client) Unsubscribe(ctx context.Context, uri string) error {
+	if err := c.checkCapability(ctx, "resources"); err != nil {
+		return err
+	}
+	c.handler.unsubscribe(uri)
+	params := UnsubscribeRequestParams{Uri: uri}
+	if err := c.call(
+		ctx, "resources/unsubscribe", params, nil,
+		attribute.String("mcp.resource.uri", uri),
+	); err != nil {
+		return fmt.Errorf("unsubscribe failed: %w", err)
+	}
+
+	return nil
+}
+
+// ListPrompts requests the list of available prompts from the server
+func (c *client) ListPrompts(
+	ctx context.Context,
+	cursor *string,
+) ([]Prompt, *string, error) {
+	if err := c.checkCapability(ctx, "prompts"); err != nil {
+		return nil, nil, err
+	}
+	params := &ListPromptsRequestParams{Cursor: cursor}
+
+	var result ListPromptsResult
+	if err := c.call(ctx, "prompts/list", params, &result, cursorAttr(cursor)); err != nil {
+		return nil, nil, fmt.Errorf("list prompts failed: %w", err)
+	}
+
+	return result.Prompts, result.NextCursor, nil
+}
+
+// GetPrompt retrieves a prompt, filling its template with args
+func (c *client) GetPrompt(
+	ctx context.Context,
+	name string,
+	args map[string]string,
+) (*GetPromptResult, error) {
+	if err := c.checkCapability(ctx, "prompts"); err != nil {
+		return nil, err
+	}
+	params := GetPromptRequestParams{
+		Name:      name,
+		Arguments: args,
+	}
+	var result GetPromptResult
+	if err := c.call(
+		ctx, "prompts/get", params, &result,
+		attribute.String("mcp.prompt.name", name),
+	); err != nil {
+		return nil, fmt.Errorf("get prompt failed: %w", err)
+	}
+
+	return &result, nil
+}
+
+// SetLevel asks the server to send log messages at the given level and above
+func (c *client) SetLevel(ctx context.Context, level LoggingLevel) error {
+	if err := c.checkCapability(ctx, "logging"); err != nil {
+		return err
+	}
+	params := SetLevelRequestParams{Level: level}
+	if err := c.call(
+		ctx, "logging/setLevel", params, nil,
+		attribute.String("mcp.logging.level", string(level)),
+	); err != nil {
+		return fmt.Errorf("set level failed: %w", err)
+	}
+
+	return nil
+}
+
+// OnLogMessage registers a callback for the log messages sent by the server
+func (c *client) OnLogMessage(fn func(LoggingMessageNotification)) func() {
+	return c.handler.onLogMessage(fn)
+}
+
+// OnNotification registers a callback for the notifications of method, or
+// of any method with "*". Callbacks are called in the order notifications
+// arrive and hold the next ones while running. The returned function
+// unregisters it.
+func (c *client) OnNotification(method string, fn NotificationHandler) func() {
+	return c.handler.onNotification(method, fn)
+}
+
+// SetSamplingHandler registers the handler answering the sampling requests
+// of the server
+func (c *client) SetSamplingHandler(fn SamplingHandler) {
+	c.handler.setSamplingHandler(fn)
+}
+
+// SetRoots sets the roots answered to the roots/list requests of the
+// server. When the roots capability was advertised with listChanged, the
+// server is notified of the change.
+func (c *client) SetRoots(roots []Root) error {
+	if err := validateRoots(roots); err != nil {
+		return err
+	}
+	c.handler.setRoots(roots)
+	return c.notifyRootsChanged()
+}
+
+// SetRootsListHandler makes the client answer roots/list with the roots
+// returned by fn, which must be file:// URIs, instead of the ones given to
+// SetRoots. Passing nil goes back to the latter.
+func (c *client) SetRootsListHandler(fn func(ctx context.Context) ([]Root, error)) {
+	c.handler.setRootsListHandler(fn)
+}
+
+// notifyRootsChanged tells an initialized server that the roots changed,
+// if the client advertised it would
+func (c *client) notifyRootsChanged() error {
+	roots := c.capabilities.Roots
+	if !c.initialized.Load() || roots == nil || roots.ListChanged == nil || !*roots.ListChanged {
+		return nil
+	}
+	if err := c.notify(c.ctx, "notifications/roots/list_changed", nil); err != nil {
+		return fmt.Errorf("failed to send roots changed notification: %w", err)
+	}
+	return nil
+}
+
 // CallTool executes a specific tool with given parameters
 func (c *client) CallTool(
 	ctx context.Context,
 	name string,
-	args map[string]interface{},
+	args interface{},
+	opts ...CallOption,
 ) (*CallToolResult, error) {
-	if !c.initialized {
-		return nil, fmt.Errorf("client not initialized")
+	if err := c.checkInitialized(ctx); err != nil {
+		return nil, err
+	}
+	arguments, err := toolArguments(args)
+	if err != nil {
+		return nil, fmt.Errorf("invalid arguments for tool %q: %w", name, err)
 	}
 	params := CallToolRequestParams{
 		Name:      name,
-		Arguments: args,
+		Arguments: arguments,
 	}
 	var result CallToolResult
-	if err := c.conn.Call(ctx, "tools/call", params).Await(ctx, &result); err != nil {
+	if err := c.callWith(
+		ctx, "tools/call", params, &result, opts,
+		attribute.String("mcp.tool.name", name),
+	); err != nil {
+		if c.stderrInErrors > 0 {
+			if lines := c.stderrTail.last(c.stderrInErrors); len(lines) > 0 {
+				return nil, fmt.Errorf(
+					"tool call failed: %w\nserver stderr:\n%s",
+					err, strings.Join(lines, "\n"),
+				)
+			}
+		}
 		return nil, fmt.Errorf("tool call failed: %w", err)
 	}
 
 	return &result, nil
 }
 
-// Close shuts down the MCP client and server
-func (c *client) Close() error {
-	// _ := context.Background()
-	if c.initialized {
-		c.initialized = false
+// toolArguments converts the arguments given to CallTool into the wire
+// representation. Anything other than a map is marshaled through JSON, so
+// struct tags such as omitempty are honored, and must encode to an object.
+func toolArguments(args interface{}) (CallToolRequestParamsArguments, error) {
+	switch v := args.(type) {
+	case nil:
+		return nil, nil
+	case CallToolRequestParamsArguments:
+		return v, nil
+	case map[string]interface{}:
+		return v, nil
 	}
 
-	// If we have an active connection, clean it up
-	if c.conn != nil {
-		ctx := context.Background()
-		// Try to send exit notification
-		_ = c.conn.Notify(ctx, "exit", nil)
-		// Close the connection
-		_ = c.conn.Close()
-		c.conn = nil
+	data, err := json.Marshal(args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal arguments: %w", err)
+	}
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil, nil
+	}
+	if len(data) == 0 || data[0] != '{' {
+		return nil, fmt.Errorf("arguments must encode to a JSON object, got %T", args)
 	}
 
+	var arguments CallToolRequestParamsArguments
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&arguments); err != nil {
+		return nil, fmt.Errorf("failed to decode arguments: %w", err)
+	}
+	return arguments, nil
+}
+
+// Close shuts down the MCP client and server. The server is asked to exit
+// by closing its stdin, then terminated and killed if it is still running
+// after the shutdown timeout. The error of a server exiting uncleanly is
+// returned. Close may be called several times and concurrently, the calls
+// after the first one wait for it and return its result.
+func (c *client) Close() error {
+	c.closeOnce.Do(func() {
+		c.closeErr = c.close()
+		if fatal := c.fatal.Load(); fatal != nil {
+			c.exit.fire(fatal)
+		} else {
+			c.exit.fire(nil)
+		}
+	})
+	return c.closeErr
+}
+
+func (c *client) close() error {
+	// Wait for a lazy start in progress, and prevent one
+	if c.lazy != nil {
+		c.lazy.mu.Lock()
+		c.lazy.start = nil
+		c.lazy.mu.Unlock()
+	}
+
+	c.initialized.Store(false)
+
 	select {
 	case <-c.ctx.Done():
+		// The connection is closed below anyway
 	default:
 		c.logger.Debug("Closing MCP client")
-		c.cancelFn()
-		// Kill the process
-		if c.cmd != nil && c.cmd.Process != nil {
-			if c.cmd.ProcessState == nil {
-				if err := c.cmd.Process.Kill(); err != nil {
-					c.logger.Error("failed to kill process", "error", err)
-				}
-				if err := c.cmd.Wait(); err != nil {
-					c.logger.Debug(
-						"Process exited",
-						"error",
-						err,
-						"code",
-						c.cmd.ProcessState.ExitCode(),
-					)
-				}
-			} else {
-				c.logger.Debug("Process already exited", "code", c.cmd.ProcessState.ExitCode())
+	}
+	c.cancelFn()
+
+	// Closing stdin is how a stdio server is told to shut down
+	if c.conn != nil {
+		_ = c.conn.Notify(context.Background(), "exit", nil)
+		_ = c.conn.Close()
+	}
+
+	if c.cmd == nil || c.cmd.Process == nil {
+		return nil
+	}
+	err := c.waitExit()
+	// Let the stderr reader forward what is left in the pipe
+	<-c.stderrDone
+
+	c.logger.Debug("MCP client closed")
+	return err
+}
+
+// waitExit waits for the server process to exit on its own for up to the
+// shutdown timeout, then terminates it with SIGTERM and, if it still runs
+// after terminateTimeout, kills it. It returns the error of Wait.
+func (c *client) waitExit() error {
+	if !c.waitDone(c.shutdownTimeout) {
+		c.logger.Warn("server did not exit in time, terminating it",
+			"timeout", c.shutdownTimeout)
+		sent, err := terminate(c.cmd.Process)
+		if err != nil {
+			c.logger.Error("failed to terminate process", "error", err)
+		}
+		if !sent || err != nil || !c.waitDone(terminateTimeout) {
+			c.logger.Warn("server did not terminate, killing it")
+			if err := c.cmd.Process.Kill(); err != nil {
+				c.logger.Error("failed to kill process", "error", err)
 			}
+			<-c.doneChan
 		}
-		// Cancel the context and wait for the process to finish
+	}
 
-		c.logger.Debug("MCP client closed")
+	if c.waitErr != nil {
+		return fmt.Errorf("server exited: %w", c.waitErr)
 	}
 	return nil
 }
+
+// waitDone reports whether the server process exits within d
+func (c *client) waitDone(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-c.doneChan:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
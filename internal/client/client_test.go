@@ -0,0 +1,597 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/jsonrpc2"
+)
+
+func TestReadResourceNotFound(t *testing.T) {
+	s := newFakeServer()
+	s.handle("resources/read", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return nil, jsonrpc2.NewError(CodeResourceNotFound, "Resource not found")
+	})
+	c := newInitializedClient(t, s)
+
+	_, err := c.ReadResource(testContext(t), "file:///missing.txt")
+	var notFound *ErrResourceNotFound
+	if !errors.As(err, &notFound) {
+		t.Fatalf("ReadResource = %v, want *ErrResourceNotFound", err)
+	}
+	if notFound.URI != "file:///missing.txt" {
+		t.Errorf("URI = %q, want file:///missing.txt", notFound.URI)
+	}
+}
+
+func TestToolsListChanged(t *testing.T) {
+	s := newFakeServer()
+	var mu sync.Mutex
+	tools := []Tool{{Name: "first"}}
+	s.handle("tools/list", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return ListToolsResult{Tools: tools}, nil
+	})
+	c := newInitializedClient(t, s)
+	ctx := testContext(t)
+
+	if got, _, err := c.ListTools(ctx, nil); err != nil || len(got) != 1 {
+		t.Fatalf("ListTools = %v, %v, want 1 tool", got, err)
+	}
+
+	changed := make(chan struct{}, 1)
+	c.OnNotification("notifications/tools/list_changed", func(context.Context, json.RawMessage) {
+		changed <- struct{}{}
+	})
+	// The server picked up a new tool definition
+	mu.Lock()
+	tools = append(tools, Tool{Name: "second"})
+	mu.Unlock()
+	s.notify(t, "notifications/tools/list_changed", nil)
+
+	select {
+	case <-changed:
+	case <-ctx.Done():
+		t.Fatal("list changed notification not received")
+	}
+	got, _, err := c.ListTools(ctx, nil)
+	if err != nil {
+		t.Fatalf("ListTools: %v", err)
+	}
+	if len(got) != 2 || got[1].Name != "second" {
+		t.Fatalf("ListTools = %v, want the new tool", got)
+	}
+}
+
+func TestGetPromptWithArguments(t *testing.T) {
+	s := newFakeServer()
+	required := true
+	s.handle("prompts/list", func(context.Context, json.RawMessage) (interface{}, error) {
+		return ListPromptsResult{Prompts: []Prompt{{
+			Name:      "greet",
+			Arguments: []PromptArgument{{Name: "name", Required: &required}},
+		}}}, nil
+	})
+	s.handle("prompts/get", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var p GetPromptRequestParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, jsonrpc2.ErrInvalidParams
+		}
+		if p.Name != "greet" || p.Arguments["name"] == "" {
+			return nil, jsonrpc2.NewError(CodeInvalidParams, "missing name")
+		}
+		return GetPromptResult{Messages: []PromptMessage{{
+			Role:    RoleUser,
+			Content: TextContent{Type: "text", Text: "Say hello to " + p.Arguments["name"]},
+		}}}, nil
+	})
+	c := newInitializedClient(t, s)
+	ctx := testContext(t)
+
+	prompts, _, err := c.ListPrompts(ctx, nil)
+	if err != nil {
+		t.Fatalf("ListPrompts: %v", err)
+	}
+	if len(prompts) != 1 || len(prompts[0].Arguments) != 1 ||
+		prompts[0].Arguments[0].Name != "name" || !*prompts[0].Arguments[0].Required {
+		t.Fatalf("ListPrompts = %+v, want greet with a required name", prompts)
+	}
+
+	result, err := c.GetPrompt(ctx, "greet", map[string]string{"name": "Ada"})
+	if err != nil {
+		t.Fatalf("GetPrompt: %v", err)
+	}
+	if len(result.Messages) != 1 || result.Messages[0].Role != RoleUser {
+		t.Fatalf("GetPrompt = %+v", result)
+	}
+	content, _ := result.Messages[0].Content.(map[string]interface{})
+	if content["text"] != "Say hello to Ada" {
+		t.Errorf("content = %v, want the filled template", result.Messages[0].Content)
+	}
+
+	if _, err := c.GetPrompt(ctx, "greet", nil); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("GetPrompt without arguments = %v, want ErrInvalidParams", err)
+	}
+}
+
+// cursor returns a pointer to s, for the pages served by the fake server
+func cursor(s string) *string {
+	return &s
+}
+
+// pageHandler serves the pages of a list method, keyed by the cursor
+// requested, "" for the first page
+func pageHandler[T any](pages map[string]T) fakeHandler {
+	return func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var p struct {
+			Cursor *string `json:"cursor"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, jsonrpc2.ErrInvalidParams
+		}
+		key := ""
+		if p.Cursor != nil {
+			key = *p.Cursor
+		}
+		page, ok := pages[key]
+		if !ok {
+			return nil, jsonrpc2.NewError(CodeInvalidParams, "unknown cursor "+key)
+		}
+		return page, nil
+	}
+}
+
+func TestListToolsPagination(t *testing.T) {
+	tests := []struct {
+		name  string
+		pages map[string]ListToolsResult
+		want  []string
+	}{
+		{
+			name: "single page",
+			pages: map[string]ListToolsResult{
+				"": {Tools: []Tool{{Name: "a"}, {Name: "b"}}},
+			},
+			want: []string{"a", "b"},
+		},
+		{
+			name: "three pages",
+			pages: map[string]ListToolsResult{
+				"":   {Tools: []Tool{{Name: "a"}}, NextCursor: cursor("p2")},
+				"p2": {Tools: []Tool{{Name: "b"}}, NextCursor: cursor("p3")},
+				"p3": {Tools: []Tool{{Name: "c"}}},
+			},
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name: "empty last cursor",
+			pages: map[string]ListToolsResult{
+				"":   {Tools: []Tool{{Name: "a"}}, NextCursor: cursor("p2")},
+				"p2": {Tools: []Tool{{Name: "b"}}, NextCursor: cursor("")},
+			},
+			want: []string{"a", "b"},
+		},
+		{
+			name: "empty page",
+			pages: map[string]ListToolsResult{
+				"":   {Tools: []Tool{}, NextCursor: cursor("p2")},
+				"p2": {Tools: []Tool{{Name: "a"}}},
+			},
+			want: []string{"a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFakeServer()
+			s.handle("tools/list", pageHandler(tt.pages))
+			c := newInitializedClient(t, s)
+			ctx := testContext(t)
+
+			var got []string
+			var next *string
+			for page := 0; ; page++ {
+				if page == len(tt.pages) {
+					t.Fatalf("still listing after %d pages", page)
+				}
+				tools, cur, err := c.ListTools(ctx, next)
+				if err != nil {
+					t.Fatalf("ListTools(%v): %v", next, err)
+				}
+				for _, tool := range tools {
+					got = append(got, tool.Name)
+				}
+				if cur == nil || *cur == "" {
+					break
+				}
+				next = cur
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("tools = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListResourceTemplatesPagination(t *testing.T) {
+	mime := "text/plain"
+	s := newFakeServer()
+	s.handle("resources/templates/list", pageHandler(map[string]ListResourceTemplatesResult{
+		"": {
+			ResourceTemplates: []ResourceTemplate{{Name: "logs", UriTemplate: "file:///logs/{date}", MimeType: &mime}},
+			NextCursor:        cursor("p2"),
+		},
+		"p2": {
+			ResourceTemplates: []ResourceTemplate{{Name: "users", UriTemplate: "db://users/{id}"}},
+		},
+	}))
+	c := newInitializedClient(t, s)
+	ctx := testContext(t)
+
+	first, next, err := c.ListResourceTemplates(ctx, nil)
+	if err != nil {
+		t.Fatalf("ListResourceTemplates: %v", err)
+	}
+	if len(first) != 1 || first[0].UriTemplate != "file:///logs/{date}" ||
+		first[0].MimeType == nil || *first[0].MimeType != mime {
+		t.Fatalf("first page = %+v", first)
+	}
+	if next == nil || *next != "p2" {
+		t.Fatalf("next cursor = %v, want p2", next)
+	}
+
+	second, next, err := c.ListResourceTemplates(ctx, next)
+	if err != nil {
+		t.Fatalf("ListResourceTemplates(p2): %v", err)
+	}
+	if len(second) != 1 || second[0].Name != "users" || next != nil {
+		t.Fatalf("second page = %+v, %v, want users and no cursor", second, next)
+	}
+}
+
+func TestInitializeClientCapabilities(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{name: "none", want: `{}`},
+		{name: "sampling", opts: []Option{WithSamplingCapability()}, want: `{"sampling":{}}`},
+		{
+			name: "sampling and roots",
+			opts: []Option{WithSamplingCapability(), WithRootsCapability(true)},
+			want: `{"roots":{"listChanged":true},"sampling":{}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFakeServer()
+			newInitializedClient(t, s, tt.opts...)
+
+			var params struct {
+				Capabilities json.RawMessage `json:"capabilities"`
+			}
+			if err := json.Unmarshal(s.waitRequest(t, "initialize", 1).Params, &params); err != nil {
+				t.Fatal(err)
+			}
+			if got := string(params.Capabilities); got != tt.want {
+				t.Errorf("capabilities sent = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInitializeClientInfo(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want Implementation
+	}{
+		{name: "default", want: Implementation{Name: "mcpkit", Version: libraryVersion()}},
+		{
+			name: "custom",
+			opts: []Option{WithClientInfo("my-agent", "1.2.3")},
+			want: Implementation{Name: "my-agent", Version: "1.2.3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFakeServer()
+			newInitializedClient(t, s, tt.opts...)
+
+			var params InitializeRequestParams
+			if err := json.Unmarshal(s.waitRequest(t, "initialize", 1).Params, &params); err != nil {
+				t.Fatal(err)
+			}
+			if params.ClientInfo != tt.want {
+				t.Errorf("clientInfo sent = %+v, want %+v", params.ClientInfo, tt.want)
+			}
+			if params.ClientInfo.Version == "" {
+				t.Error("clientInfo sent without a version")
+			}
+		})
+	}
+}
+
+func TestCloseWaitsForCleanExit(t *testing.T) {
+	cmd, args := serverCommand(t, "default")
+	c, err := newClient(context.Background(), testLogger(), cmd, args, newOptions(nil))
+	if err != nil {
+		t.Fatalf("newClient: %v", err)
+	}
+	if _, err := c.Initialize(testContext(t)); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+
+	start := time.Now()
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if d := time.Since(start); d >= defaultShutdownTimeout {
+		t.Errorf("Close took %v, the server should exit on stdin EOF", d)
+	}
+	if code := c.cmd.ProcessState.ExitCode(); code != 0 {
+		t.Errorf("exit code = %d, want 0", code)
+	}
+}
+
+func TestCloseKillsHungServer(t *testing.T) {
+	cmd, args := serverCommand(t, "hang")
+	opts := newOptions([]Option{WithShutdownTimeout(50 * time.Millisecond)})
+	c, err := newClient(context.Background(), testLogger(), cmd, args, opts)
+	if err != nil {
+		t.Fatalf("newClient: %v", err)
+	}
+	if _, err := c.Initialize(testContext(t)); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+
+	start := time.Now()
+	if err := c.Close(); err == nil {
+		t.Error("Close of a killed server returned no error")
+	}
+	if d := time.Since(start); d > terminateTimeout+time.Second {
+		t.Errorf("Close took %v", d)
+	}
+	if c.cmd.ProcessState == nil || c.cmd.ProcessState.Success() {
+		t.Errorf("server state = %v, want killed", c.cmd.ProcessState)
+	}
+}
+
+func TestCloseWhileServerExits(t *testing.T) {
+	cmd, args := serverCommand(t, "default")
+	c, err := newClient(context.Background(), testLogger(), cmd, args, newOptions(nil))
+	if err != nil {
+		t.Fatalf("newClient: %v", err)
+	}
+	ctx := testContext(t)
+	if _, err := c.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+
+	// The monitor closes the client when the server exits, racing the
+	// callers closing it too
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Close()
+		}()
+	}
+	c.CallTool(ctx, "exit", nil)
+	wg.Wait()
+
+	waitNoGoroutine(t, "(*client).monitorErrors")
+	if err := c.Ping(ctx); err == nil {
+		t.Error("Ping succeeded after Close")
+	}
+}
+
+func TestServerEnvironment(t *testing.T) {
+	t.Setenv("MCPKIT_TEST_INHERITED", "inherited")
+	tests := []struct {
+		name      string
+		opts      []Option
+		value     string
+		inherited string
+	}{
+		{name: "default", inherited: "inherited"},
+		{
+			name:      "extra",
+			opts:      []Option{WithExtraEnv(map[string]string{"MCPKIT_TEST_VALUE": "extra"})},
+			value:     "extra",
+			inherited: "inherited",
+		},
+		{
+			name:  "replaced",
+			opts:  []Option{WithEnv([]string{"MCPKIT_TEST_VALUE=replaced"})},
+			value: "replaced",
+		},
+		{
+			name: "replaced then extra",
+			opts: []Option{
+				WithEnv([]string{"MCPKIT_TEST_VALUE=replaced"}),
+				WithExtraEnv(map[string]string{"MCPKIT_TEST_VALUE": "appended"}),
+			},
+			value: "appended",
+		},
+		{
+			name: "not inherited",
+			opts: []Option{
+				WithInheritEnv(false),
+				WithExtraEnv(map[string]string{"MCPKIT_TEST_VALUE": "extra"}),
+			},
+			value: "extra",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, args := serverCommand(t, "default")
+			c, err := newClient(context.Background(), testLogger(), cmd, args, newOptions(tt.opts))
+			if err != nil {
+				t.Fatalf("newClient: %v", err)
+			}
+			defer c.Close()
+			ctx := testContext(t)
+			if _, err := c.Initialize(ctx); err != nil {
+				t.Fatalf("Initialize: %v", err)
+			}
+
+			getenv := func(name string) string {
+				t.Helper()
+				result, err := c.CallTool(ctx, "getenv", map[string]interface{}{"name": name})
+				if err != nil {
+					t.Fatalf("CallTool(getenv %s): %v", name, err)
+				}
+				texts, err := result.TextContent()
+				if err != nil || len(texts) != 1 {
+					t.Fatalf("getenv %s = %+v, %v", name, result, err)
+				}
+				return texts[0].Text
+			}
+			if got := getenv("MCPKIT_TEST_VALUE"); got != tt.value {
+				t.Errorf("MCPKIT_TEST_VALUE = %q, want %q", got, tt.value)
+			}
+			if got := getenv("MCPKIT_TEST_INHERITED"); got != tt.inherited {
+				t.Errorf("MCPKIT_TEST_INHERITED = %q, want %q", got, tt.inherited)
+			}
+		})
+	}
+}
+
+func TestNetPipe(t *testing.T) {
+	clientEnd, serverEnd := net.Pipe()
+	s := newFakeServer()
+	conn, err := s.serve(context.Background(), serverEnd)
+	if err != nil {
+		t.Fatalf("failed to start the fake server: %v", err)
+	}
+	c, err := NewFromStream(context.Background(), testLogger(), clientEnd)
+	if err != nil {
+		t.Fatalf("NewFromStream: %v", err)
+	}
+	defer c.Close()
+	ctx := testContext(t)
+
+	info, err := c.Initialize(ctx)
+	if err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	if info.ServerInfo.Name != "fake" {
+		t.Errorf("server name = %q, want fake", info.ServerInfo.Name)
+	}
+	if err := c.Ping(ctx); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+
+	// The client closes once the server hangs up
+	conn.Close()
+	select {
+	case <-c.Err():
+	case <-ctx.Done():
+		t.Fatal("the client is still open after the server closed the pipe")
+	}
+	if err := c.Ping(ctx); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Ping after the server closed = %v, want ErrClientClosed", err)
+	}
+}
+
+func TestConcurrentClose(t *testing.T) {
+	s := newFakeServer()
+	// Never answer, so that the call is in flight when the client closes
+	s.handle("tools/call", func(context.Context, json.RawMessage) (interface{}, error) {
+		return nil, jsonrpc2.ErrAsyncResponse
+	})
+	c := newInitializedClient(t, s)
+	ctx := testContext(t)
+
+	called := make(chan error, 1)
+	go func() {
+		_, err := c.CallTool(ctx, "slow", nil)
+		called <- err
+	}()
+	s.waitRequest(t, "tools/call", 1)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.Close(); err != nil {
+				t.Errorf("Close: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := <-called; !errors.Is(err, ErrClientClosed) {
+		t.Errorf("CallTool in flight = %v, want ErrClientClosed", err)
+	}
+	if _, err := c.CallTool(ctx, "slow", nil); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("CallTool after Close = %v, want ErrClientClosed", err)
+	}
+	if _, _, err := c.ListTools(ctx, nil); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("ListTools after Close = %v, want ErrClientClosed", err)
+	}
+}
+
+func TestNewAndInitialize(t *testing.T) {
+	cmd, args := serverCommand(t, "default")
+	ctx := testContext(t)
+	c, info, err := NewAndInitialize(ctx, testLogger(), cmd, args)
+	if err != nil {
+		t.Fatalf("NewAndInitialize: %v", err)
+	}
+	defer c.Close()
+
+	if info == nil || info.ServerInfo.Name != "fake" {
+		t.Fatalf("server info = %+v, want the fake server", info)
+	}
+	if !c.Supports("tools") {
+		t.Error("the client is not initialized")
+	}
+	tools, _, err := c.ListTools(ctx, nil)
+	if err != nil || len(tools) == 0 {
+		t.Errorf("ListTools = %v, %v", tools, err)
+	}
+}
+
+func TestNewAndInitializeFails(t *testing.T) {
+	cmd, args := serverCommand(t, "noinit")
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	c, info, err := NewAndInitialize(testContext(t), testLogger(), cmd, args,
+		WithExtraEnv(map[string]string{testPIDFileEnv: pidFile}))
+	if !errors.Is(err, ErrInternal) {
+		t.Fatalf("NewAndInitialize = %v, want the initialize error", err)
+	}
+	if c != nil || info != nil {
+		t.Errorf("NewAndInitialize returned %v, %v with its error", c, info)
+	}
+
+	waitNoGoroutine(t, "(*client).monitorErrors")
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatalf("the server did not start: %v", err)
+	}
+	pid, err := strconv.Atoi(string(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A server that exited but was not waited for can still be signaled
+	if p, err := os.FindProcess(pid); err == nil {
+		if err := p.Signal(syscall.Signal(0)); !errors.Is(err, os.ErrProcessDone) {
+			t.Errorf("server process %d still exists: %v", pid, err)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package client
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+const (
+	// modulePath is the path of the mcpkit module, looked up in the build
+	// info for its version
+	modulePath = "github.com/y0ug/mcpkit"
+
+	// defaultClientName is the client name sent in initialize
+	defaultClientName = "mcpkit"
+)
+
+// libraryVersion returns the version of mcpkit the program was built with,
+// "(devel)" when unknown
+var libraryVersion = sync.OnceValue(func() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(devel)"
+	}
+	if info.Main.Path == modulePath && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			if dep.Replace != nil && dep.Replace.Version != "" {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return "(devel)"
+})
+
+// defaultClientInfo is the implementation reported to the servers unless
+// WithClientInfo is given
+func defaultClientInfo() Implementation {
+	return Implementation{Name: defaultClientName, Version: libraryVersion()}
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// maxCompletionValues is the most values a completion result may hold
+const maxCompletionValues = 100
+
+// CompletionRef points at the prompt or the resource template whose
+// argument is completed. Build it with PromptRef or ResourceRef.
+type CompletionRef struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+	Uri  string `json:"uri,omitempty"`
+}
+
+// PromptRef refers to the prompt called name
+func PromptRef(name string) CompletionRef {
+	return CompletionRef{Type: "ref/prompt", Name: name}
+}
+
+// ResourceRef refers to the resource or resource template at uri
+func ResourceRef(uri string) CompletionRef {
+	return CompletionRef{Type: "ref/resource", Uri: uri}
+}
+
+// CompletionResult holds the suggested values, at most 100, and whether
+// the server has more of them
+type CompletionResult = CompleteResultCompletion
+
+// Complete asks the server for the values completing argValue for the
+// argument argName of the prompt or resource template ref
+func (c *client) Complete(
+	ctx context.Context,
+	ref CompletionRef,
+	argName, argValue string,
+) (*CompletionResult, error) {
+	if err := c.checkInitialized(ctx); err != nil {
+		return nil, err
+	}
+	params := CompleteRequestParams{
+		Ref: ref,
+		Argument: CompleteRequestParamsArgument{
+			Name:  argName,
+			Value: argValue,
+		},
+	}
+	var result CompleteResult
+	if err := c.call(
+		ctx, "completion/complete", params, &result,
+		attribute.String("mcp.completion.ref", ref.Type),
+		attribute.String("mcp.completion.argument", argName),
+	); err != nil {
+		return nil, fmt.Errorf("complete failed: %w", err)
+	}
+
+	completion := result.Completion
+	if len(completion.Values) > maxCompletionValues {
+		completion.Values = completion.Values[:maxCompletionValues]
+		hasMore := true
+		completion.HasMore = &hasMore
+	}
+	return &completion, nil
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/jsonrpc2"
+)
+
+// completer answers completion/complete with the values starting with the
+// argument value
+func completer(values []string) fakeHandler {
+	return func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var p CompleteRequestParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, jsonrpc2.ErrInvalidParams
+		}
+		matches := []string{}
+		for _, v := range values {
+			if strings.HasPrefix(v, p.Argument.Value) {
+				matches = append(matches, v)
+			}
+		}
+		total := len(matches)
+		return CompleteResult{Completion: CompleteResultCompletion{Values: matches, Total: &total}}, nil
+	}
+}
+
+func TestComplete(t *testing.T) {
+	s := newFakeServer()
+	s.handle("completion/complete", completer([]string{"python", "pytorch", "rust", "ruby"}))
+	c := newInitializedClient(t, s)
+
+	result, err := c.Complete(testContext(t), PromptRef("code_review"), "language", "py")
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if !slices.Equal(result.Values, []string{"python", "pytorch"}) {
+		t.Errorf("values = %v, want python and pytorch", result.Values)
+	}
+	if result.Total == nil || *result.Total != 2 || result.HasMore != nil {
+		t.Errorf("total = %v, hasMore = %v", result.Total, result.HasMore)
+	}
+
+	var params CompleteRequestParams
+	if err := json.Unmarshal(s.waitRequest(t, "completion/complete", 1).Params, &params); err != nil {
+		t.Fatal(err)
+	}
+	if params.Argument.Name != "language" || params.Argument.Value != "py" {
+		t.Errorf("argument sent = %+v", params.Argument)
+	}
+}
+
+func TestCompleteCapsValues(t *testing.T) {
+	var values []string
+	for i := 0; i < 150; i++ {
+		values = append(values, fmt.Sprintf("2024-01-%03d", i))
+	}
+	s := newFakeServer()
+	s.handle("completion/complete", completer(values))
+	c := newInitializedClient(t, s)
+
+	result, err := c.Complete(testContext(t), ResourceRef("file:///logs/{date}"), "date", "2024")
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if len(result.Values) != maxCompletionValues {
+		t.Errorf("got %d values, want %d", len(result.Values), maxCompletionValues)
+	}
+	if result.HasMore == nil || !*result.HasMore {
+		t.Error("hasMore not set on a truncated result")
+	}
+}
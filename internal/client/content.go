@@ -0,0 +1,104 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// TextContent returns the text items of the result, in order
+func (r *CallToolResult) TextContent() ([]TextContent, error) {
+	return contentOfType[TextContent](r.Content, "text")
+}
+
+// Text returns the text items of the result concatenated
+func (r *CallToolResult) Text() (string, error) {
+	items, err := r.TextContent()
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, item := range items {
+		b.WriteString(item.Text)
+	}
+	return b.String(), nil
+}
+
+// ImageContent returns the image items of the result, in order
+func (r *CallToolResult) ImageContent() ([]ImageContent, error) {
+	return contentOfType[ImageContent](r.Content, "image")
+}
+
+// EmbeddedResourceContent returns the embedded resource items of the
+// result, in order
+func (r *CallToolResult) EmbeddedResourceContent() ([]EmbeddedResource, error) {
+	return contentOfType[EmbeddedResource](r.Content, "resource")
+}
+
+// contentOfType decodes the items of content whose type is typ into T,
+// skipping the others
+func contentOfType[T any](content []interface{}, typ string) ([]T, error) {
+	var items []T
+	for i, item := range content {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return nil, fmt.Errorf("content %d: %w", i, err)
+		}
+
+		var head struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(data, &head); err != nil {
+			return nil, fmt.Errorf("content %d: %w", i, err)
+		}
+		if head.Type != typ {
+			continue
+		}
+
+		var v T
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("content %d: %w", i, err)
+		}
+		items = append(items, v)
+	}
+	return items, nil
+}
+
+// ToolError is returned by CallToolTyped when the tool reports a failure
+// with isError, Text is what the tool said about it
+type ToolError struct {
+	Tool string
+	Text string
+}
+
+func (e *ToolError) Error() string {
+	return fmt.Sprintf("tool %s failed: %s", e.Tool, e.Text)
+}
+
+// CallToolTyped calls the tool and decodes its text content, concatenated,
+// as JSON into a T. A result with isError set is returned as *ToolError.
+func CallToolTyped[T any](
+	ctx context.Context,
+	c Client,
+	name string,
+	args interface{},
+	opts ...CallOption,
+) (T, error) {
+	var v T
+	result, err := c.CallTool(ctx, name, args, opts...)
+	if err != nil {
+		return v, err
+	}
+	text, err := result.Text()
+	if err != nil {
+		return v, fmt.Errorf("tool %s: %w", name, err)
+	}
+	if result.IsError != nil && *result.IsError {
+		return v, &ToolError{Tool: name, Text: text}
+	}
+	if err := json.Unmarshal([]byte(text), &v); err != nil {
+		return v, fmt.Errorf("tool %s: decoding the result: %w", name, err)
+	}
+	return v, nil
+}
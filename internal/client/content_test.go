@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// currentTime is the result of the get_current_time tool of the time server
+type currentTime struct {
+	Timezone string `json:"timezone"`
+	Datetime string `json:"datetime"`
+	IsDST    bool   `json:"is_dst"`
+}
+
+func TestCallToolTyped(t *testing.T) {
+	isError := true
+	s := newFakeServer()
+	s.handle("tools/call", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var p CallToolRequestParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		switch p.Name {
+		case "get_current_time":
+			return textResult(`{"timezone":"Europe/Warsaw","datetime":"2024-01-01T13:00:00+01:00","is_dst":false}`), nil
+		case "failing":
+			result := textResult("Invalid timezone: Mars/Olympus")
+			result.IsError = &isError
+			return result, nil
+		}
+		return textResult(`{"timezone":`), nil
+	})
+	c := newInitializedClient(t, s)
+	ctx := testContext(t)
+
+	t.Run("decoded", func(t *testing.T) {
+		got, err := CallToolTyped[currentTime](ctx, c, "get_current_time",
+			map[string]interface{}{"timezone": "Europe/Warsaw"})
+		if err != nil {
+			t.Fatalf("CallToolTyped: %v", err)
+		}
+		want := currentTime{Timezone: "Europe/Warsaw", Datetime: "2024-01-01T13:00:00+01:00"}
+		if got != want {
+			t.Errorf("CallToolTyped = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("tool error", func(t *testing.T) {
+		_, err := CallToolTyped[currentTime](ctx, c, "failing", nil)
+		var toolErr *ToolError
+		if !errors.As(err, &toolErr) {
+			t.Fatalf("CallToolTyped error = %v, want *ToolError", err)
+		}
+		if toolErr.Tool != "failing" || toolErr.Text != "Invalid timezone: Mars/Olympus" {
+			t.Errorf("error = %+v", toolErr)
+		}
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		got, err := CallToolTyped[currentTime](ctx, c, "malformed", nil)
+		var syntaxErr *json.SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("CallToolTyped = %+v, %v, want a decode error", got, err)
+		}
+	})
+}
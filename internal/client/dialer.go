@@ -3,6 +3,9 @@ package client
 import (
 	"context"
 	"io"
+	"net"
+
+	"golang.org/x/exp/jsonrpc2"
 )
 
 // Implement the ReadWriteCloser interface of jsonrpc2.Dialer
@@ -34,3 +37,34 @@ func (s *StdioStream) Dial(ctx context.Context) (io.ReadWriteCloser, error) {
 	// TODO: Check if already closed
 	return s, nil
 }
+
+// Transport opens the stream to a server, which the client reads and
+// writes with its framer. StdioStream is the transport of the servers
+// started by New.
+type Transport interface {
+	Dial(ctx context.Context) (io.ReadWriteCloser, error)
+}
+
+// framedTransport is implemented by the transports carrying one message
+// per frame, such as WebSocket, which bring their own framer
+type framedTransport interface {
+	Transport
+	framer() jsonrpc2.Framer
+}
+
+// NewInMemoryTransport returns the two ends of an in-memory stream: the
+// client end, for NewFromStream, and the server end, for a server running
+// in the same process. Messages go through the same framing as with a real
+// server. A write blocks until the other end reads it.
+func NewInMemoryTransport() (clientEnd, serverEnd io.ReadWriteCloser) {
+	return net.Pipe()
+}
+
+// streamTransport dials a stream that is already open
+type streamTransport struct {
+	rwc io.ReadWriteCloser
+}
+
+func (t streamTransport) Dial(ctx context.Context) (io.ReadWriteCloser, error) {
+	return t.rwc, nil
+}
@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// drain tracks the requests waiting for the server, so that DrainAndClose
+// can let them finish
+type drain struct {
+	mu       sync.RWMutex
+	draining bool
+	pending  sync.WaitGroup
+}
+
+// track registers a request, the returned function must be called once it
+// is done. New requests fail with ErrClientClosed once draining started.
+func (d *drain) track() (func(), error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.draining {
+		return nil, ErrClientClosed
+	}
+	d.pending.Add(1)
+	return d.pending.Done, nil
+}
+
+// started reports whether DrainAndClose was called
+func (d *drain) started() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.draining
+}
+
+// wait stops new requests and waits for the pending ones until ctx is done
+func (d *drain) wait(ctx context.Context) error {
+	d.mu.Lock()
+	d.draining = true
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// DrainAndClose closes the client once the requests waiting for the server
+// got their response, failing new requests with ErrClientClosed meanwhile.
+// If ctx is done first, the client is closed anyway and the requests still
+// waiting fail, as with Close.
+func (c *client) DrainAndClose(ctx context.Context) error {
+	drainErr := c.drain.wait(ctx)
+	if drainErr != nil {
+		c.logger.Warn("closing with requests in flight", "error", drainErr)
+		drainErr = fmt.Errorf("requests still in flight: %w", drainErr)
+	}
+	return errors.Join(drainErr, c.Close())
+}
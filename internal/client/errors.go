@@ -0,0 +1,102 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// The codes of the errors sent by servers, as RPCError.Code
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+
+	// CodeResourceNotFound is the code MCP servers use when the requested
+	// resource does not exist
+	CodeResourceNotFound = -32002
+)
+
+// ErrClientClosed is returned for requests made after the client was closed,
+// either explicitly or because the server process exited
+var ErrClientClosed = errors.New("client closed")
+
+// errNotInitialized is returned for requests made before Initialize
+var errNotInitialized = errors.New("client not initialized")
+
+// interruptedError is returned for a request that was sent to the server but
+// whose response can no longer arrive because the client was closed
+type interruptedError struct {
+	method string
+	// err is ErrClientClosed, or the *FatalServerError of the server
+	err error
+}
+
+func (e *interruptedError) Error() string {
+	if e.err != ErrClientClosed {
+		return fmt.Sprintf("connection closed while waiting for %s: %v", e.method, e.err)
+	}
+	return fmt.Sprintf("connection closed while waiting for %s", e.method)
+}
+
+func (e *interruptedError) Unwrap() error {
+	return e.err
+}
+
+// ErrResourceNotFound is returned by ReadResource when the server reports
+// that no resource exists at the requested URI
+type ErrResourceNotFound struct {
+	URI string
+}
+
+func (e *ErrResourceNotFound) Error() string {
+	return fmt.Sprintf("resource not found: %s", e.URI)
+}
+
+// RPCError is an error response sent by the server. Use errors.As to read
+// its code, or errors.Is to compare it with the standard errors below.
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return e.Message
+}
+
+// Is reports whether target is an *RPCError with the same code
+func (e *RPCError) Is(target error) bool {
+	t, ok := target.(*RPCError)
+	return ok && t.Code == e.Code
+}
+
+// The standard JSON-RPC errors, matched by code with errors.Is
+var (
+	ErrParse          = &RPCError{Code: CodeParseError, Message: "parse error"}
+	ErrInvalidRequest = &RPCError{Code: CodeInvalidRequest, Message: "invalid request"}
+	ErrMethodNotFound = &RPCError{Code: CodeMethodNotFound, Message: "method not found"}
+	ErrInvalidParams  = &RPCError{Code: CodeInvalidParams, Message: "invalid params"}
+	ErrInternal       = &RPCError{Code: CodeInternalError, Message: "internal error"}
+)
+
+// toRPCError returns the error response err wraps. The framers decode the
+// error of a response as an *RPCError, see decodeMessage.
+func toRPCError(err error) (*RPCError, bool) {
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		return rpcErr, true
+	}
+	return nil, false
+}
+
+// errorCode returns the code of the error response err wraps
+func errorCode(err error) (int, bool) {
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		return rpcErr.Code, true
+	}
+	return 0, false
+}
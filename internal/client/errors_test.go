@@ -0,0 +1,78 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"golang.org/x/exp/jsonrpc2"
+)
+
+func TestCallReturnsRPCError(t *testing.T) {
+	s := newFakeServer()
+	s.handle("tools/call", func(context.Context, json.RawMessage) (interface{}, error) {
+		return nil, jsonrpc2.NewError(CodeInvalidParams, "missing argument: path")
+	})
+	c := newInitializedClient(t, s)
+
+	_, err := c.CallTool(testContext(t), "read", nil)
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) {
+		t.Fatalf("CallTool = %T %v, want *RPCError", err, err)
+	}
+	if rpcErr.Code != CodeInvalidParams || rpcErr.Message != "missing argument: path" {
+		t.Errorf("error = %d %q", rpcErr.Code, rpcErr.Message)
+	}
+	if !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("errors.Is(%v, ErrInvalidParams) = false", err)
+	}
+}
+
+func TestDecodeMessageErrorData(t *testing.T) {
+	msg, err := decodeMessage([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32002,"message":"Resource not found","data":{"uri":"file:///a"}}}`))
+	if err != nil {
+		t.Fatalf("decodeMessage: %v", err)
+	}
+	resp, ok := msg.(*jsonrpc2.Response)
+	if !ok {
+		t.Fatalf("decoded %T, want a response", msg)
+	}
+	rpcErr, ok := toRPCError(resp.Error)
+	if !ok {
+		t.Fatalf("response error %T is not an *RPCError", resp.Error)
+	}
+	if rpcErr.Code != CodeResourceNotFound || string(rpcErr.Data) != `{"uri":"file:///a"}` {
+		t.Errorf("error = %d %s", rpcErr.Code, rpcErr.Data)
+	}
+}
+
+func TestInternalErrorKeepsConnection(t *testing.T) {
+	s := newFakeServer()
+	s.handle("tools/call", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var p CallToolRequestParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, jsonrpc2.ErrInvalidParams
+		}
+		if p.Name == "panics" {
+			// What a server recovering from a panicking tool answers
+			return nil, jsonrpc2.NewError(CodeInternalError, "internal error in tool panics")
+		}
+		return textResult("ok"), nil
+	})
+	c := newInitializedClient(t, s)
+	ctx := testContext(t)
+
+	_, err := c.CallTool(ctx, "panics", nil)
+	if !errors.Is(err, ErrInternal) {
+		t.Fatalf("CallTool(panics) = %v, want ErrInternal", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := c.CallTool(ctx, "echo", nil); err != nil {
+			t.Fatalf("CallTool(echo) after the internal error: %v", err)
+		}
+	}
+	if err := c.Ping(ctx); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"fmt"
+	"sync"
+)
+
+// fatalStderrLines is the number of stderr lines kept in a FatalServerError
+const fatalStderrLines = 20
+
+// FatalServerError is the error of a client whose server process exited
+// without being asked to. It is sent on Err, and wrapped by the errors of
+// the requests that were waiting for the server or made afterwards, which
+// also match ErrClientClosed.
+type FatalServerError struct {
+	Msg string
+	// ExitCode is the exit code of the process, -1 if a signal killed it
+	ExitCode int
+	// Stderr holds the last lines the server wrote to stderr
+	Stderr []string
+	// Err is the error of waiting for the process, nil if it exited with 0
+	Err error
+}
+
+func (e *FatalServerError) Error() string {
+	return e.Msg
+}
+
+func (e *FatalServerError) Unwrap() []error {
+	return []error{ErrClientClosed, e.Err}
+}
+
+// newFatalServerError describes the exit of the server process, once the
+// stderr reader is done with it
+func (c *client) newFatalServerError() *FatalServerError {
+	<-c.stderrDone
+	e := &FatalServerError{
+		ExitCode: c.cmd.ProcessState.ExitCode(),
+		Stderr:   c.stderrTail.last(fatalStderrLines),
+		Err:      c.waitErr,
+	}
+	if e.Err != nil {
+		e.Msg = fmt.Sprintf("server exited unexpectedly: %v", e.Err)
+	} else {
+		e.Msg = "server exited unexpectedly"
+	}
+	if n := len(e.Stderr); n > 0 {
+		e.Msg += ": " + e.Stderr[n-1]
+	}
+	return e
+}
+
+// closedErr is the error of the requests made to a closed client, the
+// *FatalServerError when the server died
+func (c *client) closedErr() error {
+	if fatal := c.fatal.Load(); fatal != nil {
+		return fatal
+	}
+	return ErrClientClosed
+}
+
+// Err returns a channel receiving a *FatalServerError if the server process
+// dies. It is closed once the client is closed, without a value when Close
+// was called first.
+func (c *client) Err() <-chan error {
+	return c.exit.ch
+}
+
+// errNotifier delivers the error a client failed with to the channel
+// returned by Err
+type errNotifier struct {
+	once sync.Once
+	ch   chan error
+}
+
+func newErrNotifier() *errNotifier {
+	return &errNotifier{ch: make(chan error, 1)}
+}
+
+// fire sends err, unless nil, and closes the channel. Only the first call
+// counts.
+func (n *errNotifier) fire(err error) {
+	n.once.Do(func() {
+		if err != nil {
+			n.ch <- err
+		}
+		close(n.ch)
+	})
+}
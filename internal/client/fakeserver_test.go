@@ -0,0 +1,338 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/jsonrpc2"
+)
+
+// testServerArg is the first argument of the test binary started as a
+// server by serverCommand
+const testServerArg = "mcpkit-test-server"
+
+// testPIDFileEnv names the file a test server writes its PID to
+const testPIDFileEnv = "MCPKIT_TEST_PIDFILE"
+
+// TestMain runs the test binary as an MCP server when a test starts it with
+// serverCommand
+func TestMain(m *testing.M) {
+	if len(os.Args) == 3 && os.Args[1] == testServerArg {
+		os.Exit(runTestServer(os.Args[2]))
+	}
+	os.Exit(m.Run())
+}
+
+// serverCommand returns the command starting the test binary as a stdio
+// server in mode, see runTestServer
+func serverCommand(t testing.TB, mode string) (string, []string) {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("failed to find the test binary: %v", err)
+	}
+	return exe, []string{testServerArg, mode}
+}
+
+// runTestServer serves a fake server on stdio until stdin is closed. Its
+// tools are "getenv", returning the variable named by the "name" argument,
+// "sleep", answering after the "ms" argument, and "exit", making the
+// process exit with code 3. In "hang" mode the process keeps running once
+// stdin is closed and ignores SIGTERM, so that it has to be killed. In
+// "noinit" mode initialize fails. The process writes its PID to the file
+// named by testPIDFileEnv, when set.
+func runTestServer(mode string) int {
+	if mode == "hang" {
+		signal.Ignore(syscall.SIGTERM)
+	}
+	if path := os.Getenv(testPIDFileEnv); path != "" {
+		if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())), 0o600); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	s := newFakeServer()
+	if mode == "noinit" {
+		s.handle("initialize", func(context.Context, json.RawMessage) (interface{}, error) {
+			return nil, jsonrpc2.NewError(CodeInternalError, "server not ready")
+		})
+	}
+	s.handle("tools/list", func(context.Context, json.RawMessage) (interface{}, error) {
+		return ListToolsResult{Tools: []Tool{{Name: "getenv"}, {Name: "sleep"}, {Name: "exit"}}}, nil
+	})
+	s.handle("tools/call", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var p CallToolRequestParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, jsonrpc2.ErrInvalidParams
+		}
+		switch p.Name {
+		case "getenv":
+			name, _ := p.Arguments["name"].(string)
+			return textResult(os.Getenv(name)), nil
+		case "sleep":
+			ms, _ := p.Arguments["ms"].(float64)
+			time.Sleep(time.Duration(ms) * time.Millisecond)
+			return textResult("awake"), nil
+		case "exit":
+			fmt.Fprintln(os.Stderr, "fatal: exit tool called")
+			os.Exit(3)
+		}
+		return nil, jsonrpc2.NewError(CodeInvalidParams, "unknown tool "+p.Name)
+	})
+
+	conn, err := s.serve(context.Background(), stdio{os.Stdin, os.Stdout})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	conn.Wait()
+	if mode == "hang" {
+		time.Sleep(time.Minute)
+	}
+	return 0
+}
+
+// stdio is the stream of a server reading stdin and writing stdout
+type stdio struct {
+	io.Reader
+	io.WriteCloser
+}
+
+// fakeHandler answers a request, or handles a notification, sent to a
+// fakeServer
+type fakeHandler func(ctx context.Context, params json.RawMessage) (interface{}, error)
+
+// fakeServer is an MCP server scripted by the tests. It answers initialize
+// with the capabilities in caps, and the requests with an empty result such
+// as ping. It records every message the client sends.
+type fakeServer struct {
+	mu       sync.Mutex
+	handlers map[string]fakeHandler
+	received []*jsonrpc2.Request
+	// caps are the capabilities sent in the initialize result, as on the
+	// wire since empty objects advertise a capability
+	caps map[string]interface{}
+	// version is answered to initialize, the requested one when empty
+	version string
+	conn    *jsonrpc2.Connection
+}
+
+func newFakeServer() *fakeServer {
+	return &fakeServer{
+		handlers: make(map[string]fakeHandler),
+		caps: map[string]interface{}{
+			"tools":     map[string]interface{}{},
+			"resources": map[string]interface{}{"subscribe": true},
+			"prompts":   map[string]interface{}{},
+			"logging":   map[string]interface{}{},
+		},
+	}
+}
+
+// handle makes the server answer method with fn, in place of the default
+// answer or of a method not found error
+func (s *fakeServer) handle(method string, fn fakeHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method] = fn
+}
+
+// serve serves the client at the other end of rwc
+func (s *fakeServer) serve(ctx context.Context, rwc io.ReadWriteCloser) (*jsonrpc2.Connection, error) {
+	conn, err := jsonrpc2.Dial(ctx, streamTransport{rwc}, s)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.conn = conn
+	s.mu.Unlock()
+	return conn, nil
+}
+
+func (s *fakeServer) Bind(context.Context, *jsonrpc2.Connection) (jsonrpc2.ConnectionOptions, error) {
+	return jsonrpc2.ConnectionOptions{
+		Framer:  NewLineRawFramer(),
+		Handler: jsonrpc2.HandlerFunc(s.handleRequest),
+	}, nil
+}
+
+func (s *fakeServer) handleRequest(ctx context.Context, req *jsonrpc2.Request) (interface{}, error) {
+	s.mu.Lock()
+	s.received = append(s.received, req)
+	fn := s.handlers[req.Method]
+	s.mu.Unlock()
+
+	if fn != nil {
+		return fn(ctx, req.Params)
+	}
+	if !req.IsCall() {
+		return nil, nil
+	}
+	switch req.Method {
+	case "initialize":
+		return s.initializeResult(req.Params)
+	case "ping", "logging/setLevel", "resources/subscribe", "resources/unsubscribe":
+		return struct{}{}, nil
+	}
+	return nil, jsonrpc2.ErrMethodNotFound
+}
+
+func (s *fakeServer) initializeResult(params json.RawMessage) (interface{}, error) {
+	var p InitializeRequestParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, jsonrpc2.ErrInvalidParams
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	version := s.version
+	if version == "" {
+		version = p.ProtocolVersion
+	}
+	return map[string]interface{}{
+		"protocolVersion": version,
+		"capabilities":    s.caps,
+		"serverInfo":      Implementation{Name: "fake", Version: "1.0.0"},
+	}, nil
+}
+
+// requests returns the messages of method received so far
+func (s *fakeServer) requests(method string) []*jsonrpc2.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var reqs []*jsonrpc2.Request
+	for _, req := range s.received {
+		if req.Method == method {
+			reqs = append(reqs, req)
+		}
+	}
+	return reqs
+}
+
+// methods returns the methods of the messages received so far, in order
+func (s *fakeServer) methods() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	methods := make([]string, len(s.received))
+	for i, req := range s.received {
+		methods[i] = req.Method
+	}
+	return methods
+}
+
+// waitRequest waits for the n-th message of method and returns it
+func (s *fakeServer) waitRequest(t testing.TB, method string, n int) *jsonrpc2.Request {
+	t.Helper()
+	var reqs []*jsonrpc2.Request
+	eventually(t, func() bool {
+		reqs = s.requests(method)
+		return len(reqs) >= n
+	}, "server did not receive %d %s", n, method)
+	return reqs[n-1]
+}
+
+// notify sends a notification to the client
+func (s *fakeServer) notify(t testing.TB, method string, params interface{}) {
+	t.Helper()
+	s.mu.Lock()
+	conn := s.conn
+	s.mu.Unlock()
+	if err := conn.Notify(context.Background(), method, params); err != nil {
+		t.Fatalf("failed to send %s: %v", method, err)
+	}
+}
+
+// callClient sends a request to the client and decodes its result
+func (s *fakeServer) callClient(ctx context.Context, method string, params, result interface{}) error {
+	s.mu.Lock()
+	conn := s.conn
+	s.mu.Unlock()
+	return conn.Call(ctx, method, params).Await(ctx, result)
+}
+
+// newTestClient connects a client to s through an in-memory transport. The
+// client is closed when the test ends.
+func newTestClient(t testing.TB, s *fakeServer, opts ...Option) *client {
+	t.Helper()
+	return newLoggedTestClient(t, s, testLogger(), opts...)
+}
+
+// newLoggedTestClient is newTestClient with a client logging to logger
+func newLoggedTestClient(t testing.TB, s *fakeServer, logger *slog.Logger, opts ...Option) *client {
+	t.Helper()
+	clientEnd, serverEnd := NewInMemoryTransport()
+	conn, err := s.serve(context.Background(), serverEnd)
+	if err != nil {
+		t.Fatalf("failed to start the fake server: %v", err)
+	}
+	c, err := NewFromStream(context.Background(), logger, clientEnd, opts...)
+	if err != nil {
+		t.Fatalf("failed to create the client: %v", err)
+	}
+	t.Cleanup(func() {
+		c.Close()
+		conn.Close()
+	})
+	return c.(*client)
+}
+
+// newInitializedClient is newTestClient followed by Initialize
+func newInitializedClient(t testing.TB, s *fakeServer, opts ...Option) *client {
+	t.Helper()
+	c := newTestClient(t, s, opts...)
+	if _, err := c.Initialize(testContext(t)); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	return c
+}
+
+// testContext returns a context bounding a test to a few seconds
+func testContext(t testing.TB) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// eventually fails the test if cond is still false after a few seconds
+func eventually(t testing.TB, cond func() bool, format string, args ...interface{}) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf(format, args...)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// waitNoGoroutine waits for the goroutines running fn, a function name as
+// printed in stack traces, to exit
+func waitNoGoroutine(t testing.TB, fn string) {
+	t.Helper()
+	eventually(t, func() bool {
+		buf := make([]byte, 1<<20)
+		return !strings.Contains(string(buf[:runtime.Stack(buf, true)]), fn)
+	}, "a goroutine is still running %s", fn)
+}
+
+func textResult(text string) CallToolResult {
+	return CallToolResult{Content: []interface{}{
+		map[string]interface{}{"type": "text", "text": text},
+	}}
+}
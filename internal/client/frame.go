@@ -2,63 +2,221 @@ package client
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
+	"os"
+	"strconv"
 	"strings"
+	"sync/atomic"
 
 	"golang.org/x/exp/jsonrpc2"
 )
 
-// LoggingFramer is a Framer decorator that logs frames on read/write.
+// defaultWireLogMaxBytes truncates the messages logged by a LoggingFramer
+const defaultWireLogMaxBytes = 4096
+
+// defaultRedactedFields are the JSON fields a LoggingFramer does not log
+var defaultRedactedFields = []string{
+	"authorization",
+	"token",
+	"access_token",
+	"refresh_token",
+	"password",
+	"secret",
+	"api_key",
+	"apikey",
+}
+
+// LoggingFramer is a Framer decorator that logs frames on read/write, with
+// their direction, method, ID, size and JSON.
 type LoggingFramer struct {
 	Base jsonrpc2.Framer // the underlying framer (e.g., HeaderFramer, RawFramer, etc.)
+
+	// Logger receives the frame logs. When nil they go to stderr, never to
+	// stdout which may be the transport itself.
+	Logger *slog.Logger
+
+	// Level is the level of the logs, debug when nil
+	Level slog.Leveler
+
+	// MaxBytes truncates the JSON logged, 4096 bytes when zero and no
+	// limit when negative
+	MaxBytes int
+
+	// Redact lists the JSON fields, at any depth, whose values are not
+	// logged. Names are compared ignoring case. When nil, fields such as
+	// "authorization", "token" or "password" are redacted.
+	Redact []string
+}
+
+func (f *LoggingFramer) logger() *slog.Logger {
+	if f.Logger != nil {
+		return f.Logger
+	}
+	// The default handler level is info, which would drop debug frames
+	opts := &slog.HandlerOptions{Level: f.level()}
+	return slog.New(slog.NewTextHandler(os.Stderr, opts))
+}
+
+func (f *LoggingFramer) level() slog.Level {
+	if f.Level != nil {
+		return f.Level.Level()
+	}
+	return slog.LevelDebug
+}
+
+func (f *LoggingFramer) wireLogger() *wireLogger {
+	l := &wireLogger{
+		logger:   f.logger(),
+		level:    f.level(),
+		maxBytes: f.MaxBytes,
+		redact:   make(map[string]bool),
+	}
+	if l.maxBytes == 0 {
+		l.maxBytes = defaultWireLogMaxBytes
+	}
+	redact := f.Redact
+	if redact == nil {
+		redact = defaultRedactedFields
+	}
+	for _, field := range redact {
+		l.redact[strings.ToLower(field)] = true
+	}
+	return l
 }
 
 // Reader wraps the underlying framer's Reader with logging.
 func (f *LoggingFramer) Reader(r io.Reader) jsonrpc2.Reader {
 	baseReader := f.Base.Reader(r)
-	return &loggingReader{base: baseReader}
+	return &loggingReader{base: baseReader, logger: f.wireLogger()}
 }
 
 // Writer wraps the underlying framer's Writer with logging.
 func (f *LoggingFramer) Writer(w io.Writer) jsonrpc2.Writer {
 	baseWriter := f.Base.Writer(w)
-	return &loggingWriter{base: baseWriter}
+	return &loggingWriter{base: baseWriter, logger: f.wireLogger()}
+}
+
+// wireLogger logs the messages exchanged with the server
+type wireLogger struct {
+	logger   *slog.Logger
+	level    slog.Level
+	maxBytes int
+	redact   map[string]bool
+}
+
+func (l *wireLogger) log(ctx context.Context, direction string, msg jsonrpc2.Message, n int64) {
+	if !l.logger.Enabled(ctx, l.level) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("direction", direction),
+	}
+	switch msg := msg.(type) {
+	case *jsonrpc2.Request:
+		attrs = append(attrs, slog.String("method", msg.Method))
+		if msg.IsCall() {
+			attrs = append(attrs, slog.Any("id", msg.ID.Raw()))
+		}
+	case *jsonrpc2.Response:
+		attrs = append(attrs, slog.Any("id", msg.ID.Raw()))
+	}
+	attrs = append(attrs, slog.Int64("bytes", n), slog.String("json", l.format(msg)))
+	l.logger.LogAttrs(ctx, l.level, "wire", attrs...)
+}
+
+// failed logs a failed read or write at debug level, reads fail as well
+// when the client is closed
+func (l *wireLogger) failed(ctx context.Context, direction string, err error) {
+	l.logger.Debug("wire failed", "direction", direction, "error", err)
+}
+
+// format returns the JSON of msg with the secrets redacted, truncated
+func (l *wireLogger) format(msg jsonrpc2.Message) string {
+	data, err := jsonrpc2.EncodeMessage(msg)
+	if err != nil {
+		return fmt.Sprintf("%+v", msg)
+	}
+	if len(l.redact) > 0 {
+		var v interface{}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if dec.Decode(&v) == nil {
+			if redacted, err := json.Marshal(l.redactValue(v)); err == nil {
+				data = redacted
+			}
+		}
+	}
+	if l.maxBytes > 0 && len(data) > l.maxBytes {
+		return fmt.Sprintf("%s... (%d bytes)", data[:l.maxBytes], len(data))
+	}
+	return string(data)
+}
+
+func (l *wireLogger) redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if l.redact[strings.ToLower(key)] {
+				v[key] = "[REDACTED]"
+			} else {
+				v[key] = l.redactValue(value)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = l.redactValue(item)
+		}
+	}
+	return v
 }
 
 // loggingReader implements Reader, wrapping calls to base.Read with logging.
 type loggingReader struct {
-	base jsonrpc2.Reader
+	base   jsonrpc2.Reader
+	logger *wireLogger
 }
 
 func (r *loggingReader) Read(ctx context.Context) (jsonrpc2.Message, int64, error) {
 	msg, n, err := r.base.Read(ctx)
 	if err != nil {
-		// Log the read error if desired
-		fmt.Printf("[LoggingReader] Error: %v\n", err)
+		r.logger.failed(ctx, "recv", err)
 		return msg, n, err
 	}
-	// Log the successfully read frame
-	fmt.Printf("[LoggingReader] Read %d bytes: %+v\n", n, msg)
+	r.logger.log(ctx, "recv", msg, n)
 	return msg, n, err
 }
 
 // loggingWriter implements Writer, wrapping calls to base.Write with logging.
 type loggingWriter struct {
-	base jsonrpc2.Writer
+	base   jsonrpc2.Writer
+	logger *wireLogger
 }
 
 func (w *loggingWriter) Write(ctx context.Context, msg jsonrpc2.Message) (int64, error) {
 	n, err := w.base.Write(ctx, msg)
 	if err != nil {
-		// Log the write error if desired
-		fmt.Printf("[LoggingWriter] Error: %v\n", err)
+		w.logger.failed(ctx, "send", err)
 		return n, err
 	}
-	// Log the successfully written frame
-	fmt.Printf("[LoggingWriter] Wrote %d bytes: %+v\n", n, msg)
+	w.logger.log(ctx, "send", msg, n)
+	return n, err
+}
+
+func (w *loggingWriter) writeBatch(ctx context.Context, msgs []jsonrpc2.Message) (int64, error) {
+	n, err := writeBatch(ctx, w.base, msgs)
+	if err != nil {
+		w.logger.failed(ctx, "send", err)
+		return n, err
+	}
+	// The size is the one of the whole batch
+	for _, msg := range msgs {
+		w.logger.log(ctx, "send", msg, n)
+	}
 	return n, err
 }
 
@@ -73,6 +231,8 @@ type newLineRawFramer struct{}
 
 type newLineRawReader struct {
 	in *bufio.Reader
+	// pending holds the messages left from a batch
+	pending []jsonrpc2.Message
 }
 
 type newLineRawWriter struct {
@@ -88,22 +248,36 @@ func (newLineRawFramer) Writer(w io.Writer) jsonrpc2.Writer {
 }
 
 func (r *newLineRawReader) Read(ctx context.Context) (jsonrpc2.Message, int64, error) {
+	if msg, ok := popPending(&r.pending); ok {
+		return msg, 0, nil
+	}
+
 	select {
 	case <-ctx.Done():
 		return nil, 0, ctx.Err()
 	default:
 	}
 
-	// Read until the newline character
-	line, err := r.in.ReadString('\n')
-	if err != nil {
-		return nil, 0, fmt.Errorf("failed to read line: %w", err)
-	}
+	// Read until a line holding a message, some servers print stray blank
+	// lines between them
+	var line string
+	for len(line) == 0 {
+		var err error
+		line, err = r.in.ReadString('\n')
+		if err == io.EOF {
+			// A clean EOF is how the server closes the stream, it is returned
+			// as is for the connection to shut down quietly
+			if strings.TrimSpace(line) == "" {
+				return nil, 0, io.EOF
+			}
+			return nil, int64(len(line)), fmt.Errorf("stream ended mid-message: %w", io.ErrUnexpectedEOF)
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read line: %w", err)
+		}
 
-	// Trim the newline and any other trailing whitespace
-	line = strings.TrimSpace(line)
-	if len(line) == 0 {
-		return nil, 0, fmt.Errorf("empty message")
+		// Trim the newline and any other trailing whitespace
+		line = strings.TrimSpace(line)
 	}
 
 	// Unmarshal the JSON message
@@ -112,8 +286,12 @@ func (r *newLineRawReader) Read(ctx context.Context) (jsonrpc2.Message, int64, e
 		return nil, 0, fmt.Errorf("failed to unmarshal JSON: %w", err)
 	}
 
-	msg, err := jsonrpc2.DecodeMessage(raw)
-	return msg, int64(len(line)), err
+	msgs, err := decodeFrame(raw)
+	if err != nil {
+		return nil, int64(len(line)), err
+	}
+	r.pending = msgs[1:]
+	return msgs[0], int64(len(line)), nil
 }
 
 func (w *newLineRawWriter) Write(ctx context.Context, msg jsonrpc2.Message) (int64, error) {
@@ -134,3 +312,360 @@ func (w *newLineRawWriter) Write(ctx context.Context, msg jsonrpc2.Message) (int
 	n, err := w.out.Write(data)
 	return int64(n), err
 }
+
+// writeBatch writes msgs as a single JSON array on one line
+func (w *newLineRawWriter) writeBatch(ctx context.Context, msgs []jsonrpc2.Message) (int64, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+
+	data, err := encodeBatch(msgs)
+	if err != nil {
+		return 0, err
+	}
+	data = append(data, '\n')
+
+	n, err := w.out.Write(data)
+	return int64(n), err
+}
+
+// batchWriter is implemented by the writers able to send several messages
+// as a JSON-RPC batch
+type batchWriter interface {
+	writeBatch(ctx context.Context, msgs []jsonrpc2.Message) (int64, error)
+}
+
+// writeBatch writes msgs with w as a single batch when it supports it, one
+// by one otherwise
+func writeBatch(ctx context.Context, w jsonrpc2.Writer, msgs []jsonrpc2.Message) (int64, error) {
+	if bw, ok := w.(batchWriter); ok {
+		return bw.writeBatch(ctx, msgs)
+	}
+	var total int64
+	for _, msg := range msgs {
+		n, err := w.Write(ctx, msg)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// encodeBatch encodes msgs as a JSON array
+func encodeBatch(msgs []jsonrpc2.Message) ([]byte, error) {
+	batch := make([]json.RawMessage, len(msgs))
+	for i, msg := range msgs {
+		data, err := jsonrpc2.EncodeMessage(msg)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling message: %w", err)
+		}
+		batch[i] = data
+	}
+	return json.Marshal(batch)
+}
+
+// decodeMessage decodes a message as jsonrpc2.DecodeMessage does, with the
+// error of a response decoded as an *RPCError. jsonrpc2 does not export its
+// own error type, so callers could not read the code of the error otherwise.
+func decodeMessage(data []byte) (jsonrpc2.Message, error) {
+	msg, err := jsonrpc2.DecodeMessage(data)
+	if err != nil {
+		return nil, err
+	}
+	resp, ok := msg.(*jsonrpc2.Response)
+	if !ok || resp.Error == nil {
+		return msg, nil
+	}
+	var wire struct {
+		Error *RPCError `json:"error"`
+	}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal error response: %w", err)
+	}
+	if wire.Error != nil {
+		resp.Error = wire.Error
+	}
+	return msg, nil
+}
+
+// decodeFrame decodes the message of a frame, or the messages of a batch
+// when the frame holds a JSON array
+func decodeFrame(data []byte) ([]jsonrpc2.Message, error) {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		msg, err := decodeMessage(data)
+		if err != nil {
+			return nil, err
+		}
+		return []jsonrpc2.Message{msg}, nil
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(trimmed, &batch); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal batch: %w", err)
+	}
+	if len(batch) == 0 {
+		return nil, fmt.Errorf("empty batch")
+	}
+	msgs := make([]jsonrpc2.Message, len(batch))
+	for i, raw := range batch {
+		msg, err := decodeMessage(raw)
+		if err != nil {
+			return nil, err
+		}
+		msgs[i] = msg
+	}
+	return msgs, nil
+}
+
+// popPending removes and returns the first message of pending
+func popPending(pending *[]jsonrpc2.Message) (jsonrpc2.Message, bool) {
+	if len(*pending) == 0 {
+		return nil, false
+	}
+	msg := (*pending)[0]
+	*pending = (*pending)[1:]
+	return msg, true
+}
+
+// NewHeaderFramer returns a Framer that prefixes each message with a
+// Content-Length header, as in the LSP base protocol:
+//
+//	Content-Length: 42\r\n
+//	\r\n
+//	{"jsonrpc":"2.0",...}
+//
+// Header names are matched case-insensitively and unknown headers, such as
+// Content-Type, are ignored.
+func NewHeaderFramer() jsonrpc2.Framer {
+	return headerFramer{}
+}
+
+type headerFramer struct{}
+
+type headerReader struct {
+	in *bufio.Reader
+	// pending holds the messages left from a batch
+	pending []jsonrpc2.Message
+}
+
+type headerWriter struct {
+	out io.Writer
+}
+
+func (headerFramer) Reader(r io.Reader) jsonrpc2.Reader {
+	return &headerReader{in: bufio.NewReader(r)}
+}
+
+func (headerFramer) Writer(w io.Writer) jsonrpc2.Writer {
+	return &headerWriter{out: w}
+}
+
+func (r *headerReader) Read(ctx context.Context) (jsonrpc2.Message, int64, error) {
+	if msg, ok := popPending(&r.pending); ok {
+		return msg, 0, nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	default:
+	}
+
+	var total, length int64
+	// Read the headers up to the first empty line
+	for {
+		line, err := r.in.ReadString('\n')
+		total += int64(len(line))
+		if err == io.EOF {
+			if strings.TrimSpace(line) == "" && total == int64(len(line)) {
+				return nil, total, io.EOF
+			}
+			return nil, total, fmt.Errorf("stream ended mid-message: %w", io.ErrUnexpectedEOF)
+		}
+		if err != nil {
+			return nil, total, fmt.Errorf("failed to read header: %w", err)
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, total, fmt.Errorf("invalid header line %q", line)
+		}
+		if strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			length, err = strconv.ParseInt(strings.TrimSpace(value), 10, 32)
+			if err != nil || length <= 0 {
+				return nil, total, fmt.Errorf("invalid Content-Length %q", value)
+			}
+		}
+	}
+	if length == 0 {
+		return nil, total, fmt.Errorf("missing Content-Length header")
+	}
+
+	data := make([]byte, length)
+	n, err := io.ReadFull(r.in, data)
+	total += int64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, total, fmt.Errorf("failed to read body: %w", err)
+	}
+
+	msgs, err := decodeFrame(data)
+	if err != nil {
+		return nil, total, err
+	}
+	r.pending = msgs[1:]
+	return msgs[0], total, nil
+}
+
+func (w *headerWriter) Write(ctx context.Context, msg jsonrpc2.Message) (int64, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+
+	data, err := jsonrpc2.EncodeMessage(msg)
+	if err != nil {
+		return 0, fmt.Errorf("marshaling message: %w", err)
+	}
+
+	return w.writeFrame(data)
+}
+
+// writeBatch writes msgs as a single JSON array in one frame
+func (w *headerWriter) writeBatch(ctx context.Context, msgs []jsonrpc2.Message) (int64, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+
+	data, err := encodeBatch(msgs)
+	if err != nil {
+		return 0, err
+	}
+	return w.writeFrame(data)
+}
+
+func (w *headerWriter) writeFrame(data []byte) (int64, error) {
+	// Write the header and the body at once so that concurrent writers on
+	// the same stream never interleave them
+	frame := make([]byte, 0, len(data)+32)
+	frame = fmt.Appendf(frame, "Content-Length: %d\r\n\r\n", len(data))
+	frame = append(frame, data...)
+
+	n, err := w.out.Write(frame)
+	return int64(n), err
+}
+
+const (
+	frameModeUnknown int32 = iota
+	frameModeLine
+	frameModeHeader
+)
+
+// NewAutoFramer returns a Framer that detects from the first bytes sent by
+// the server whether it uses newline or Content-Length framing, and then
+// writes with the same framing.
+//
+// Until the server has sent something, messages are written with newline
+// framing, which is the one of the MCP stdio transport. The detection is
+// kept by the framer, so a server restarted with the same framer is written
+// to with the right framing from the start.
+func NewAutoFramer() jsonrpc2.Framer {
+	return &autoFramer{}
+}
+
+type autoFramer struct {
+	mode atomic.Int32
+}
+
+func (f *autoFramer) Reader(r io.Reader) jsonrpc2.Reader {
+	return &autoReader{framer: f, in: bufio.NewReader(r)}
+}
+
+func (f *autoFramer) Writer(w io.Writer) jsonrpc2.Writer {
+	return &autoWriter{
+		framer: f,
+		line:   &newLineRawWriter{out: w},
+		header: &headerWriter{out: w},
+	}
+}
+
+type autoReader struct {
+	framer *autoFramer
+	in     *bufio.Reader
+	base   jsonrpc2.Reader
+}
+
+func (r *autoReader) Read(ctx context.Context) (jsonrpc2.Message, int64, error) {
+	if r.base == nil {
+		mode, err := sniffFrameMode(r.in)
+		if err == io.EOF {
+			return nil, 0, io.EOF
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to detect framing: %w", err)
+		}
+		r.framer.mode.Store(mode)
+
+		if mode == frameModeHeader {
+			r.base = &headerReader{in: r.in}
+		} else {
+			r.base = &newLineRawReader{in: r.in}
+		}
+	}
+	return r.base.Read(ctx)
+}
+
+// sniffFrameMode skips the leading whitespace of in and looks at the next
+// byte: a JSON value starts a newline framed stream, anything else is taken
+// as a header
+func sniffFrameMode(in *bufio.Reader) (int32, error) {
+	for {
+		b, err := in.Peek(1)
+		if err != nil {
+			return frameModeUnknown, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			_, _ = in.ReadByte()
+		case '{', '[':
+			return frameModeLine, nil
+		default:
+			return frameModeHeader, nil
+		}
+	}
+}
+
+type autoWriter struct {
+	framer *autoFramer
+	line   jsonrpc2.Writer
+	header jsonrpc2.Writer
+}
+
+func (w *autoWriter) Write(ctx context.Context, msg jsonrpc2.Message) (int64, error) {
+	return w.current().Write(ctx, msg)
+}
+
+func (w *autoWriter) writeBatch(ctx context.Context, msgs []jsonrpc2.Message) (int64, error) {
+	return writeBatch(ctx, w.current(), msgs)
+}
+
+func (w *autoWriter) current() jsonrpc2.Writer {
+	if w.framer.mode.Load() == frameModeHeader {
+		return w.header
+	}
+	return w.line
+}
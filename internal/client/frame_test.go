@@ -0,0 +1,171 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/jsonrpc2"
+)
+
+func TestLoggingFramerKeepsTransportClean(t *testing.T) {
+	ctx := context.Background()
+	msg, err := jsonrpc2.NewCall(jsonrpc2.Int64ID(1), "tools/list", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame, err := jsonrpc2.EncodeMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	for _, tt := range []struct {
+		name   string
+		framer *LoggingFramer
+	}{
+		{"logger", &LoggingFramer{Base: NewLineRawFramer(), Logger: logger}},
+		{"default logger", &LoggingFramer{Base: NewLineRawFramer()}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// Nothing may reach stdout either, the transport of stdio servers
+			stdout := os.Stdout
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			os.Stdout = w
+			defer func() { os.Stdout = stdout }()
+
+			var transport bytes.Buffer
+			if _, err := tt.framer.Writer(&transport).Write(ctx, msg); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			if got, want := transport.String(), string(frame)+"\n"; got != want {
+				t.Errorf("transport got %q, want only the frame %q", got, want)
+			}
+			read, _, err := tt.framer.Reader(strings.NewReader(transport.String())).Read(ctx)
+			if err != nil {
+				t.Fatalf("Read: %v", err)
+			}
+			if call, ok := read.(*jsonrpc2.Request); !ok || call.Method != "tools/list" {
+				t.Errorf("read %#v, want the tools/list call", read)
+			}
+
+			os.Stdout = stdout
+			w.Close()
+			if out, _ := io.ReadAll(r); len(out) > 0 {
+				t.Errorf("stdout got %q", out)
+			}
+		})
+	}
+	if !strings.Contains(logs.String(), "tools/list") {
+		t.Errorf("frames not logged: %q", logs.String())
+	}
+}
+
+func TestLineReaderSkipsBlankLines(t *testing.T) {
+	ctx := context.Background()
+	stream := "\n" +
+		`{"jsonrpc":"2.0","id":1,"result":{}}` + "\n" +
+		"  \r\n\t\n\n" +
+		`{"jsonrpc":"2.0","method":"notifications/message"}` + "\r\n" +
+		"\n"
+	r := NewLineRawFramer().Reader(strings.NewReader(stream))
+
+	msg, _, err := r.Read(ctx)
+	if err != nil {
+		t.Fatalf("first Read: %v", err)
+	}
+	if resp, ok := msg.(*jsonrpc2.Response); !ok || resp.ID != jsonrpc2.Int64ID(1) {
+		t.Errorf("first message = %#v, want the response to 1", msg)
+	}
+	msg, _, err = r.Read(ctx)
+	if err != nil {
+		t.Fatalf("second Read: %v", err)
+	}
+	if req, ok := msg.(*jsonrpc2.Request); !ok || req.Method != "notifications/message" {
+		t.Errorf("second message = %#v, want the notification", msg)
+	}
+	if _, _, err := r.Read(ctx); err != io.EOF {
+		t.Errorf("Read at the end = %v, want io.EOF", err)
+	}
+}
+
+func TestLineReaderEOF(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		stream  string
+		msgs    int
+		wantEOF bool
+	}{
+		{name: "empty stream", stream: "", wantEOF: true},
+		{name: "after a message", stream: `{"jsonrpc":"2.0","id":1,"result":{}}` + "\n", msgs: 1, wantEOF: true},
+		{name: "after blank lines", stream: `{"jsonrpc":"2.0","id":1,"result":{}}` + "\n\n  ", msgs: 1, wantEOF: true},
+		{name: "mid-line", stream: `{"jsonrpc":"2.0","id":1,"res`},
+		{name: "message without newline", stream: `{"jsonrpc":"2.0","id":1,"result":{}}`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			r := NewLineRawFramer().Reader(strings.NewReader(tt.stream))
+			for i := 0; i < tt.msgs; i++ {
+				if _, _, err := r.Read(ctx); err != nil {
+					t.Fatalf("Read %d: %v", i, err)
+				}
+			}
+
+			_, _, err := r.Read(ctx)
+			if tt.wantEOF {
+				// Unwrapped, for jsonrpc2 to close the connection quietly
+				if err != io.EOF {
+					t.Errorf("Read = %v, want io.EOF", err)
+				}
+				return
+			}
+			if err == io.EOF || !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Errorf("Read = %v, want io.ErrUnexpectedEOF", err)
+			}
+		})
+	}
+}
+
+func TestLoggingFramerDefaultLogger(t *testing.T) {
+	msg, err := jsonrpc2.NewCall(jsonrpc2.Int64ID(1), "tools/list", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name   string
+		framer *LoggingFramer
+	}{
+		{"debug", &LoggingFramer{Base: NewLineRawFramer()}},
+		{"warn", &LoggingFramer{Base: NewLineRawFramer(), Level: slog.LevelWarn}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			stderr := os.Stderr
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			os.Stderr = w
+			defer func() { os.Stderr = stderr }()
+
+			writer := tt.framer.Writer(io.Discard)
+			os.Stderr = stderr
+			if _, err := writer.Write(context.Background(), msg); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			w.Close()
+			out, _ := io.ReadAll(r)
+			if !strings.Contains(string(out), "tools/list") {
+				t.Errorf("stderr got %q, want the frame logged", out)
+			}
+		})
+	}
+}
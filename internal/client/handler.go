@@ -0,0 +1,364 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/jsonrpc2"
+)
+
+// NotificationHandler is called with the params of a notification sent by
+// the server
+type NotificationHandler func(ctx context.Context, params json.RawMessage)
+
+// SamplingHandler answers the sampling/createMessage requests of the server
+// by asking the host LLM to generate a message
+type SamplingHandler func(
+	ctx context.Context,
+	params CreateMessageRequestParams,
+) (CreateMessageResult, error)
+
+// dispatcher handles the requests and notifications sent by the server and
+// routes them to the callbacks registered on the client
+type dispatcher struct {
+	logger *slog.Logger
+	tracer trace.Tracer
+	// spans holds the calls waiting for the server
+	spans spanSet
+
+	mu          sync.RWMutex
+	nextID      int
+	logHandlers map[int]func(LoggingMessageNotification)
+	// subscriptions maps a subscribed resource URI to its update callback
+	subscriptions map[string]func(uri string)
+	sampling      SamplingHandler
+	// roots are listed to the server on roots/list, unless rootsHandler
+	// is set
+	roots        []Root
+	rootsHandler func(ctx context.Context) ([]Root, error)
+	// notificationHandlers maps a notification method, or "*" for all of
+	// them, to the handlers registered with OnNotification
+	notificationHandlers map[string]map[int]NotificationHandler
+}
+
+func newDispatcher(logger *slog.Logger, tracer trace.Tracer) *dispatcher {
+	return &dispatcher{
+		logger:      logger,
+		tracer:      tracer,
+		logHandlers: make(map[int]func(LoggingMessageNotification)),
+
+		subscriptions: make(map[string]func(uri string)),
+
+		notificationHandlers: make(map[string]map[int]NotificationHandler),
+	}
+}
+
+// binder returns the options of a connection using framer whose incoming
+// messages are handled by d
+func (d *dispatcher) binder(framer jsonrpc2.Framer) jsonrpc2.Binder {
+	return connectionBinder{dispatcher: d, framer: framer}
+}
+
+type connectionBinder struct {
+	dispatcher *dispatcher
+	framer     jsonrpc2.Framer
+}
+
+func (b connectionBinder) Bind(
+	ctx context.Context,
+	conn *jsonrpc2.Connection,
+) (jsonrpc2.ConnectionOptions, error) {
+	handler := func(ctx context.Context, req *jsonrpc2.Request) (interface{}, error) {
+		return b.dispatcher.handle(ctx, conn, req)
+	}
+	return jsonrpc2.ConnectionOptions{
+		Framer:  b.framer,
+		Handler: jsonrpc2.HandlerFunc(handler),
+	}, nil
+}
+
+func (d *dispatcher) handle(
+	ctx context.Context,
+	conn *jsonrpc2.Connection,
+	req *jsonrpc2.Request,
+) (interface{}, error) {
+	if !req.IsCall() {
+		// Notifications never get a response, not even an error
+		d.handleNotification(ctx, conn, req)
+		return nil, nil
+	}
+
+	switch req.Method {
+	case "ping":
+		// The server checks that the client is alive, the result is empty
+		return struct{}{}, nil
+	case "sampling/createMessage":
+		if fn := d.samplingHandler(); fn != nil {
+			return d.respondAsync(ctx, conn, req, func(ctx context.Context) (interface{}, error) {
+				var params CreateMessageRequestParams
+				if err := json.Unmarshal(req.Params, &params); err != nil {
+					return nil, fmt.Errorf("%w: %v", jsonrpc2.ErrInvalidParams, err)
+				}
+				result, err := fn(ctx, params)
+				if err != nil {
+					return nil, fmt.Errorf("%w: sampling failed: %v", jsonrpc2.ErrInternal, err)
+				}
+				return result, nil
+			})
+		}
+	case "roots/list":
+		fn := d.rootsListHandler()
+		if fn == nil {
+			return ListRootsResult{Roots: d.listRoots()}, nil
+		}
+		return d.respondAsync(ctx, conn, req, func(ctx context.Context) (interface{}, error) {
+			roots, err := fn(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("%w: listing roots failed: %v", jsonrpc2.ErrInternal, err)
+			}
+			if err := validateRoots(roots); err != nil {
+				return nil, fmt.Errorf("%w: %v", jsonrpc2.ErrInternal, err)
+			}
+			return ListRootsResult{Roots: append([]Root{}, roots...)}, nil
+		})
+	}
+
+	d.logger.Info("Request received",
+		"method", req.Method,
+		"id", req.ID.Raw(),
+		"params", string(req.Params))
+	return nil, jsonrpc2.ErrNotHandled
+}
+
+// handleNotification passes a notification to the client features handling
+// it and to the handlers registered with OnNotification
+func (d *dispatcher) handleNotification(
+	ctx context.Context,
+	conn *jsonrpc2.Connection,
+	req *jsonrpc2.Request,
+) {
+	handled := true
+	switch req.Method {
+	case "notifications/cancelled":
+		d.handleCancelled(conn, req)
+	case "notifications/message":
+		d.handleLogMessage(req)
+	case "notifications/resources/updated":
+		d.handleResourceUpdated(req)
+	default:
+		handled = false
+	}
+
+	handlers := d.notificationHandlersFor(req.Method)
+	for _, fn := range handlers {
+		fn(ctx, req.Params)
+	}
+
+	if !handled && len(handlers) == 0 {
+		d.logger.Debug("Unhandled notification",
+			"method", req.Method,
+			"params", string(req.Params))
+	}
+}
+
+// notificationHandlersFor returns the handlers registered for method, then
+// the catch-all ones
+func (d *dispatcher) notificationHandlersFor(method string) []NotificationHandler {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	var handlers []NotificationHandler
+	for _, key := range []string{method, "*"} {
+		for _, fn := range d.notificationHandlers[key] {
+			handlers = append(handlers, fn)
+		}
+	}
+	return handlers
+}
+
+func (d *dispatcher) onNotification(method string, fn NotificationHandler) func() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	id := d.nextID
+	d.nextID++
+	if d.notificationHandlers[method] == nil {
+		d.notificationHandlers[method] = make(map[int]NotificationHandler)
+	}
+	d.notificationHandlers[method][id] = fn
+
+	return func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		delete(d.notificationHandlers[method], id)
+		if len(d.notificationHandlers[method]) == 0 {
+			delete(d.notificationHandlers, method)
+		}
+	}
+}
+
+// respondAsync answers req with the result of fn, run in its own goroutine so
+// that a slow callback does not hold the other messages sent by the server.
+// fn runs in the span of the request, tied to the calls waiting.
+func (d *dispatcher) respondAsync(
+	ctx context.Context,
+	conn *jsonrpc2.Connection,
+	req *jsonrpc2.Request,
+	fn func(ctx context.Context) (interface{}, error),
+) (interface{}, error) {
+	go func() {
+		ctx, span := d.startSpan(ctx, req)
+		defer span.End()
+		result, err := fn(ctx)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		if err := conn.Respond(req.ID, result, err); err != nil {
+			d.logger.Warn("failed to respond", "method", req.Method, "error", err)
+		}
+	}()
+	return nil, jsonrpc2.ErrAsyncResponse
+}
+
+// handleCancelled cancels the context of a request the server gave up on
+func (d *dispatcher) handleCancelled(conn *jsonrpc2.Connection, req *jsonrpc2.Request) {
+	var params struct {
+		RequestId json.RawMessage `json:"requestId"`
+	}
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		d.logger.Warn("invalid cancelled notification", "error", err)
+		return
+	}
+
+	var id int64
+	var name string
+	switch {
+	case json.Unmarshal(params.RequestId, &id) == nil:
+		conn.Cancel(jsonrpc2.Int64ID(id))
+	case json.Unmarshal(params.RequestId, &name) == nil:
+		conn.Cancel(jsonrpc2.StringID(name))
+	default:
+		d.logger.Warn("invalid request id in cancelled notification",
+			"id", string(params.RequestId))
+	}
+}
+
+func (d *dispatcher) samplingHandler() SamplingHandler {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.sampling
+}
+
+func (d *dispatcher) setSamplingHandler(fn SamplingHandler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sampling = fn
+}
+
+// listRoots returns a copy of the roots, empty until SetRoots is called
+func (d *dispatcher) listRoots() []Root {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return append([]Root{}, d.roots...)
+}
+
+func (d *dispatcher) setRoots(roots []Root) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.roots = append([]Root{}, roots...)
+}
+
+func (d *dispatcher) rootsListHandler() func(ctx context.Context) ([]Root, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.rootsHandler
+}
+
+func (d *dispatcher) setRootsListHandler(fn func(ctx context.Context) ([]Root, error)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.rootsHandler = fn
+}
+
+func (d *dispatcher) handleLogMessage(req *jsonrpc2.Request) {
+	msg := LoggingMessageNotification{Method: req.Method}
+	if err := json.Unmarshal(req.Params, &msg.Params); err != nil {
+		d.logger.Warn("invalid log message notification", "error", err)
+		return
+	}
+
+	for _, fn := range d.logMessageHandlers() {
+		fn(msg)
+	}
+}
+
+// logMessageHandlers returns the handlers registered with OnLogMessage, so
+// that they run without the lock and can remove themselves
+func (d *dispatcher) logMessageHandlers() []func(LoggingMessageNotification) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	handlers := make([]func(LoggingMessageNotification), 0, len(d.logHandlers))
+	for _, fn := range d.logHandlers {
+		handlers = append(handlers, fn)
+	}
+	return handlers
+}
+
+// onLogMessage registers fn and returns a function removing it
+func (d *dispatcher) onLogMessage(fn func(LoggingMessageNotification)) func() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	id := d.nextID
+	d.nextID++
+	d.logHandlers[id] = fn
+
+	return func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		delete(d.logHandlers, id)
+	}
+}
+
+// handleResourceUpdated calls the callback of the subscription to the
+// updated URI. It runs without the lock so that it can unsubscribe.
+func (d *dispatcher) handleResourceUpdated(req *jsonrpc2.Request) {
+	var params ResourceUpdatedNotificationParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		d.logger.Warn("invalid resource updated notification", "error", err)
+		return
+	}
+
+	d.mu.RLock()
+	fn := d.subscriptions[params.Uri]
+	d.mu.RUnlock()
+	if fn != nil {
+		fn(params.Uri)
+	}
+}
+
+func (d *dispatcher) subscribe(uri string, fn func(uri string)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.subscriptions[uri] = fn
+}
+
+func (d *dispatcher) unsubscribe(uri string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.subscriptions, uri)
+}
+
+// subscribedURIs returns the URIs that currently have a subscription
+func (d *dispatcher) subscribedURIs() []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	uris := make([]string, 0, len(d.subscriptions))
+	for uri := range d.subscriptions {
+		uris = append(uris, uri)
+	}
+	return uris
+}
@@ -0,0 +1,146 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLogMessages(t *testing.T) {
+	s := newFakeServer()
+	c := newInitializedClient(t, s)
+	ctx := testContext(t)
+
+	if err := c.SetLevel(ctx, LoggingLevelWarning); err != nil {
+		t.Fatalf("SetLevel: %v", err)
+	}
+	var params SetLevelRequestParams
+	if err := json.Unmarshal(s.waitRequest(t, "logging/setLevel", 1).Params, &params); err != nil {
+		t.Fatal(err)
+	}
+	if params.Level != LoggingLevelWarning {
+		t.Errorf("level sent = %q, want %q", params.Level, LoggingLevelWarning)
+	}
+
+	// The handler removes itself while it runs
+	received := make(chan LoggingMessageNotification, 2)
+	var remove func()
+	remove = c.OnLogMessage(func(msg LoggingMessageNotification) {
+		received <- msg
+		remove()
+	})
+	delivered := make(chan struct{}, 2)
+	c.OnNotification("notifications/message", func(context.Context, json.RawMessage) {
+		delivered <- struct{}{}
+	})
+
+	for _, data := range []string{"disk almost full", "disk full"} {
+		s.notify(t, "notifications/message", LoggingMessageNotificationParams{
+			Level: LoggingLevelWarning,
+			Data:  data,
+		})
+		select {
+		case <-delivered:
+		case <-ctx.Done():
+			t.Fatal("log message not delivered, the handler may be deadlocked")
+		}
+	}
+
+	if len(received) != 1 {
+		t.Fatalf("handler called %d times, want once before removing itself", len(received))
+	}
+	msg := <-received
+	if msg.Params.Level != LoggingLevelWarning || msg.Params.Data != "disk almost full" {
+		t.Errorf("message = %+v", msg.Params)
+	}
+}
+
+func TestSubscribeUpdates(t *testing.T) {
+	s := newFakeServer()
+	c := newInitializedClient(t, s)
+	ctx := testContext(t)
+
+	delivered := make(chan struct{}, 4)
+	c.OnNotification("notifications/resources/updated", func(context.Context, json.RawMessage) {
+		delivered <- struct{}{}
+	})
+	update := func(uri string) {
+		t.Helper()
+		s.notify(t, "notifications/resources/updated", ResourceUpdatedNotificationParams{Uri: uri})
+		select {
+		case <-delivered:
+		case <-ctx.Done():
+			t.Fatalf("update of %s not delivered, the callback may be deadlocked", uri)
+		}
+	}
+
+	updates := make(chan string, 4)
+	err := c.Subscribe(ctx, "file:///logs/1", func(uri string) {
+		updates <- uri
+		// Unsubscribing from the callback must not deadlock
+		if err := c.Unsubscribe(ctx, uri); err != nil {
+			t.Errorf("Unsubscribe: %v", err)
+		}
+	})
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	var params SubscribeRequestParams
+	if err := json.Unmarshal(s.waitRequest(t, "resources/subscribe", 1).Params, &params); err != nil {
+		t.Fatal(err)
+	}
+	if params.Uri != "file:///logs/1" {
+		t.Errorf("subscribed to %q, want file:///logs/1", params.Uri)
+	}
+
+	// Only the subscribed URI itself is reported
+	update("file:///logs/10")
+	update("file:///logs/1")
+	if got := <-updates; got != "file:///logs/1" {
+		t.Errorf("callback called with %q, want file:///logs/1", got)
+	}
+	s.waitRequest(t, "resources/unsubscribe", 1)
+
+	update("file:///logs/1")
+	if len(updates) != 0 {
+		t.Errorf("callback called for %q", <-updates)
+	}
+}
+
+func TestServerPing(t *testing.T) {
+	clientEnd, serverEnd := NewInMemoryTransport()
+	c, err := NewFromStream(context.Background(), testLogger(), clientEnd)
+	if err != nil {
+		t.Fatalf("NewFromStream: %v", err)
+	}
+	defer c.Close()
+	// Nothing reads what the client sends on Close
+	defer serverEnd.Close()
+	serverEnd.(net.Conn).SetDeadline(time.Now().Add(5 * time.Second))
+
+	// Script the server by hand to see the response as sent on the wire
+	if _, err := io.WriteString(serverEnd, `{"jsonrpc":"2.0","id":"srv-7","method":"ping"}`+"\n"); err != nil {
+		t.Fatalf("failed to send ping: %v", err)
+	}
+	line, err := bufio.NewReader(serverEnd).ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("failed to read the response: %v", err)
+	}
+	var resp struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Result  json.RawMessage `json:"result"`
+		Error   json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(line, &resp); err != nil {
+		t.Fatalf("invalid response %s: %v", line, err)
+	}
+	if resp.JSONRPC != "2.0" || string(resp.ID) != `"srv-7"` ||
+		string(resp.Result) != `{}` || resp.Error != nil {
+		t.Errorf("response = %s, want an empty result for srv-7", line)
+	}
+}
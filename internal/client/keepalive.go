@@ -0,0 +1,118 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultKeepaliveFailures is the number of consecutive failed pings after
+// which the server is reported unhealthy
+const defaultKeepaliveFailures = 3
+
+// keepalive holds the settings and the state of the keepalive pings
+type keepalive struct {
+	interval        time.Duration
+	timeout         time.Duration
+	maxFailures     int
+	onUnhealthy     func(err error)
+	killOnUnhealthy bool
+
+	once      sync.Once
+	unhealthy atomic.Bool
+	// lastPong is the time of the last answered ping, in Unix nanoseconds
+	lastPong atomic.Int64
+}
+
+// Healthy reports whether the client is initialized, not closed and, with
+// WithKeepalive, whether the server answers the keepalive pings
+func (c *client) Healthy() bool {
+	return c.initialized.Load() && c.ctx.Err() == nil && !c.keepalive.unhealthy.Load()
+}
+
+// LastPong returns when the server last answered a keepalive ping, the zero
+// time if it never did
+func (c *client) LastPong() time.Time {
+	ns := c.keepalive.lastPong.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// startKeepalive starts the keepalive loop if enabled, once per client
+func (c *client) startKeepalive() {
+	if c.keepalive.interval <= 0 {
+		return
+	}
+	c.keepalive.once.Do(func() {
+		go c.runKeepalive()
+	})
+}
+
+// runKeepalive pings the server every interval until the client is closed
+func (c *client) runKeepalive() {
+	ticker := time.NewTicker(c.keepalive.interval)
+	defer ticker.Stop()
+
+	failures := 0
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := c.keepalivePing()
+		if c.ctx.Err() != nil || c.drain.started() {
+			return
+		}
+		if err == nil {
+			failures = 0
+			c.keepalive.lastPong.Store(time.Now().UnixNano())
+			if c.keepalive.unhealthy.Swap(false) {
+				c.logger.Info("server is healthy again")
+			}
+			continue
+		}
+
+		failures++
+		c.logger.Warn("keepalive ping failed", "failures", failures, "error", err)
+		if failures != c.keepalive.maxFailures {
+			continue
+		}
+
+		c.keepalive.unhealthy.Store(true)
+		c.logger.Error("server is unhealthy", "error", err)
+		if c.keepalive.onUnhealthy != nil {
+			c.keepalive.onUnhealthy(err)
+		}
+		if c.keepalive.killOnUnhealthy {
+			c.killUnhealthy()
+		} else if c.keepalive.onUnhealthy == nil {
+			// Nobody is told, fail the calls instead of letting them hang
+			_ = c.Close()
+			return
+		}
+	}
+}
+
+// killUnhealthy kills the server so that the resilient client restarts it
+// once it exits
+func (c *client) killUnhealthy() {
+	if c.cmd == nil || c.cmd.Process == nil {
+		return
+	}
+	if err := c.cmd.Process.Kill(); err != nil {
+		c.logger.Error("failed to kill unhealthy server", "error", err)
+	}
+}
+
+func (c *client) keepalivePing() error {
+	opts := []CallOption{CallWithPriority(PriorityHigh)}
+	if c.keepalive.timeout > 0 {
+		opts = append(opts, CallWithTimeout(c.keepalive.timeout))
+	}
+	return c.callWith(context.Background(), "ping", nil, nil, opts)
+}
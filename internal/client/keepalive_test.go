@@ -0,0 +1,138 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/jsonrpc2"
+)
+
+// recordingHandler keeps the records logged at warning level and above
+type recordingHandler struct {
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (h *recordingHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= slog.LevelWarn
+}
+
+func (h *recordingHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, r.Clone())
+	return nil
+}
+
+func (h *recordingHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *recordingHandler) WithGroup(string) slog.Handler      { return h }
+
+func (h *recordingHandler) messages() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var msgs []string
+	for _, r := range h.records {
+		msgs = append(msgs, r.Message)
+	}
+	return msgs
+}
+
+func TestKeepaliveStopsOnClose(t *testing.T) {
+	s := newFakeServer()
+	// Slow pings are likely to be in flight when the client closes
+	s.handle("ping", func(context.Context, json.RawMessage) (interface{}, error) {
+		time.Sleep(time.Millisecond)
+		return struct{}{}, nil
+	})
+	logs := &recordingHandler{}
+	c := newLoggedTestClient(t, s, slog.New(logs), WithKeepalive(time.Millisecond, time.Second))
+	if _, err := c.Initialize(testContext(t)); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+
+	eventually(t, func() bool { return !c.LastPong().IsZero() }, "no keepalive ping answered")
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	waitNoGoroutine(t, "(*client).runKeepalive")
+	if msgs := logs.messages(); len(msgs) > 0 {
+		t.Errorf("logged on shutdown: %q", msgs)
+	}
+}
+
+func TestKeepaliveUnhealthy(t *testing.T) {
+	failPing := func(context.Context, json.RawMessage) (interface{}, error) {
+		return nil, jsonrpc2.NewError(CodeInternalError, "stuck")
+	}
+
+	t.Run("callback", func(t *testing.T) {
+		s := newFakeServer()
+		s.handle("ping", failPing)
+		unhealthy := make(chan error, 1)
+		c := newInitializedClient(t, s,
+			WithKeepalive(time.Millisecond, time.Second),
+			WithKeepaliveFailures(2),
+			WithOnUnhealthy(func(err error) { unhealthy <- err }))
+
+		select {
+		case err := <-unhealthy:
+			if !errors.Is(err, ErrInternal) {
+				t.Errorf("OnUnhealthy(%v), want the ping error", err)
+			}
+		case <-testContext(t).Done():
+			t.Fatal("OnUnhealthy was not called")
+		}
+		if c.Healthy() {
+			t.Error("Healthy after failed pings")
+		}
+		if c.ctx.Err() != nil {
+			t.Error("the client was closed although OnUnhealthy is set")
+		}
+	})
+
+	t.Run("close", func(t *testing.T) {
+		s := newFakeServer()
+		s.handle("ping", failPing)
+		c := newInitializedClient(t, s,
+			WithKeepalive(time.Millisecond, time.Second),
+			WithKeepaliveFailures(2))
+
+		eventually(t, func() bool { return c.ctx.Err() != nil }, "the client was not closed")
+		if _, err := c.CallTool(testContext(t), "echo", nil); !errors.Is(err, ErrClientClosed) {
+			t.Errorf("CallTool = %v, want ErrClientClosed", err)
+		}
+	})
+}
+
+func TestKeepaliveGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		s := newFakeServer()
+		clientEnd, serverEnd := NewInMemoryTransport()
+		conn, err := s.serve(context.Background(), serverEnd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := NewFromStream(context.Background(), testLogger(), clientEnd,
+			WithKeepalive(time.Millisecond, time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Initialize(testContext(t)); err != nil {
+			t.Fatalf("Initialize: %v", err)
+		}
+		c.Close()
+		conn.Close()
+	}
+
+	eventually(t, func() bool {
+		return runtime.NumGoroutine() <= before
+	}, "goroutines leaked, %d were running before the clients", before)
+}
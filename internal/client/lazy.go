@@ -0,0 +1,31 @@
+package client
+
+import "sync"
+
+// lazyStart holds the start of a server deferred with WithLazyStart
+type lazyStart struct {
+	mu sync.Mutex
+	// start is nil once run, or once the client is closed
+	start func() error
+	err   error
+}
+
+// ensureStarted starts the server if the client was created with
+// WithLazyStart and it is not running yet. Concurrent requests wait for a
+// single start, and all get its error.
+func (c *client) ensureStarted() error {
+	if c.lazy == nil {
+		return nil
+	}
+	c.lazy.mu.Lock()
+	defer c.lazy.mu.Unlock()
+	if c.lazy.start != nil {
+		c.lazy.err = c.lazy.start()
+		c.lazy.start = nil
+	}
+	if c.lazy.err == nil && c.conn == nil {
+		// Closed before starting
+		return c.closedErr()
+	}
+	return c.lazy.err
+}
@@ -0,0 +1,149 @@
+package client
+
+import (
+	"expvar"
+	"sync"
+	"time"
+)
+
+// Collector receives the requests and notifications sent to the server, to
+// be bridged to a metrics system such as Prometheus. tool is the tool
+// called by tools/call requests, empty otherwise. The methods are called on
+// the goroutine of the request and must not block.
+type Collector interface {
+	RequestStarted(method, tool string)
+	RequestFinished(method, tool string, d time.Duration, err error)
+}
+
+// MethodStats are the requests of a method seen by a MemoryCollector
+type MethodStats struct {
+	Requests int64
+	Errors   int64
+	// Duration is the total time spent waiting for the server
+	Duration time.Duration
+}
+
+// MemoryCollector is a Collector keeping the counters in memory, for tests
+// and simple reporting
+type MemoryCollector struct {
+	mu       sync.Mutex
+	inFlight int
+	methods  map[string]MethodStats
+	tools    map[string]MethodStats
+}
+
+// NewMemoryCollector creates an empty MemoryCollector
+func NewMemoryCollector() *MemoryCollector {
+	return &MemoryCollector{
+		methods: make(map[string]MethodStats),
+		tools:   make(map[string]MethodStats),
+	}
+}
+
+func (m *MemoryCollector) RequestStarted(method, tool string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight++
+}
+
+func (m *MemoryCollector) RequestFinished(method, tool string, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight--
+	m.methods[method] = m.methods[method].add(d, err)
+	if tool != "" {
+		m.tools[tool] = m.tools[tool].add(d, err)
+	}
+}
+
+func (s MethodStats) add(d time.Duration, err error) MethodStats {
+	s.Requests++
+	s.Duration += d
+	if err != nil {
+		s.Errors++
+	}
+	return s
+}
+
+// InFlight returns the number of requests waiting for the server
+func (m *MemoryCollector) InFlight() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.inFlight
+}
+
+// Methods returns the finished requests by method
+func (m *MemoryCollector) Methods() map[string]MethodStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return copyStats(m.methods)
+}
+
+// Tools returns the finished tools/call requests by tool
+func (m *MemoryCollector) Tools() map[string]MethodStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return copyStats(m.tools)
+}
+
+func copyStats(stats map[string]MethodStats) map[string]MethodStats {
+	c := make(map[string]MethodStats, len(stats))
+	for k, v := range stats {
+		c[k] = v
+	}
+	return c
+}
+
+// ExpvarMetrics is a Collector publishing the counters with expvar, served
+// on /debug/vars by the default HTTP mux
+type ExpvarMetrics struct {
+	inFlight *expvar.Int
+	requests *expvar.Map
+	errors   *expvar.Map
+	seconds  *expvar.Map
+	tools    *expvar.Map
+}
+
+// NewExpvarMetrics publishes the counters as the expvar map name, holding
+// in_flight, plus requests, errors and seconds by method and tools calls
+// by tool. Like expvar.Publish, it panics if name is already published.
+func NewExpvarMetrics(name string) *ExpvarMetrics {
+	m := &ExpvarMetrics{
+		inFlight: new(expvar.Int),
+		requests: new(expvar.Map).Init(),
+		errors:   new(expvar.Map).Init(),
+		seconds:  new(expvar.Map).Init(),
+		tools:    new(expvar.Map).Init(),
+	}
+	vars := expvar.NewMap(name)
+	vars.Set("in_flight", m.inFlight)
+	vars.Set("requests", m.requests)
+	vars.Set("errors", m.errors)
+	vars.Set("seconds", m.seconds)
+	vars.Set("tools", m.tools)
+	return m
+}
+
+func (m *ExpvarMetrics) RequestStarted(method, tool string) {
+	m.inFlight.Add(1)
+}
+
+func (m *ExpvarMetrics) RequestFinished(method, tool string, d time.Duration, err error) {
+	m.inFlight.Add(-1)
+	m.requests.Add(method, 1)
+	m.seconds.AddFloat(method, d.Seconds())
+	if err != nil {
+		m.errors.Add(method, 1)
+	}
+	if tool != "" {
+		m.tools.Add(tool, 1)
+	}
+}
+
+// toolName returns the tool called by the params of a request, if any
+func toolName(params interface{}) string {
+	if p, ok := params.(CallToolRequestParams); ok {
+		return p.Name
+	}
+	return ""
+}
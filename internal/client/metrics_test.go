@@ -0,0 +1,101 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/jsonrpc2"
+)
+
+// finishedRequest is a request reported to RequestFinished
+type finishedRequest struct {
+	method, tool string
+	err          error
+}
+
+// recordingCollector keeps the finished requests, in order
+type recordingCollector struct {
+	mu       sync.Mutex
+	started  int
+	finished []finishedRequest
+}
+
+func (c *recordingCollector) RequestStarted(method, tool string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.started++
+}
+
+func (c *recordingCollector) RequestFinished(method, tool string, d time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.finished = append(c.finished, finishedRequest{method: method, tool: tool, err: err})
+}
+
+// call returns the tools/call request of tool that finished last
+func (c *recordingCollector) call(tool string) (finishedRequest, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := len(c.finished) - 1; i >= 0; i-- {
+		if r := c.finished[i]; r.method == "tools/call" && r.tool == tool {
+			return r, true
+		}
+	}
+	return finishedRequest{}, false
+}
+
+// inFlight returns the number of requests started but not finished
+func (c *recordingCollector) inFlight() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.started - len(c.finished)
+}
+
+func TestMetrics(t *testing.T) {
+	release := make(chan struct{})
+	s := newFakeServer()
+	s.handle("tools/call", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var p CallToolRequestParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, jsonrpc2.ErrInvalidParams
+		}
+		switch p.Name {
+		case "fails":
+			return nil, jsonrpc2.NewError(CodeInvalidParams, "bad arguments")
+		case "hangs":
+			<-release
+		}
+		return textResult("ok"), nil
+	})
+	collector := &recordingCollector{}
+	c := newInitializedClient(t, s, WithMetrics(collector), WithToolTimeout(50*time.Millisecond))
+	t.Cleanup(func() { close(release) })
+	ctx := testContext(t)
+
+	for _, tt := range []struct {
+		tool    string
+		wantErr func(error) bool
+	}{
+		{tool: "echo", wantErr: func(err error) bool { return err == nil }},
+		{tool: "fails", wantErr: func(err error) bool { return errors.Is(err, ErrInvalidParams) }},
+		{tool: "hangs", wantErr: func(err error) bool {
+			var timeout *ErrRequestTimeout
+			return errors.As(err, &timeout)
+		}},
+	} {
+		c.CallTool(ctx, tt.tool, nil)
+		got, ok := collector.call(tt.tool)
+		if !ok {
+			t.Errorf("%s: tools/call not reported", tt.tool)
+		} else if !tt.wantErr(got.err) {
+			t.Errorf("%s: reported with error %v", tt.tool, got.err)
+		}
+	}
+	if n := collector.inFlight(); n != 0 {
+		t.Errorf("%d requests still in flight", n)
+	}
+}
@@ -0,0 +1,546 @@
+package client
+
+import (
+	"crypto/tls"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"golang.org/x/exp/jsonrpc2"
+)
+
+// Option configures a client created by New
+type Option func(*options)
+
+// defaultShutdownTimeout is how long Close waits for the server to exit
+// before killing it
+const defaultShutdownTimeout = 5 * time.Second
+
+// defaultConnectTimeout bounds connecting to a network server
+const defaultConnectTimeout = 10 * time.Second
+
+// terminateTimeout is how long Close waits for the server to exit after
+// SIGTERM before killing it
+const terminateTimeout = 2 * time.Second
+
+type options struct {
+	tracerProvider trace.TracerProvider
+	stderrWriter   io.Writer
+	frameLogger    *slog.Logger
+	framer         jsonrpc2.Framer
+	capabilities   ClientCapabilities
+	clientInfo     Implementation
+	// env replaces the inherited environment when set
+	env            []string
+	extraEnv       map[string]string
+	inheritEnv     bool
+	workingDir     string
+	cmdCustomizers []func(*exec.Cmd)
+	requestTimeout time.Duration
+	// methodTimeouts overrides requestTimeout per method category, the part
+	// of the method name before the slash ("tools", "resources", ...)
+	methodTimeouts map[string]time.Duration
+	// batchTimeout bounds the batches, overriding requestTimeout
+	batchTimeout time.Duration
+	// metrics receives the requests when set
+	metrics Collector
+
+	// shutdownTimeout is how long Close waits for the server to exit
+	shutdownTimeout time.Duration
+
+	// connectTimeout and tlsConfig apply to servers reached over the
+	// network, with NewTCP
+	connectTimeout time.Duration
+	tlsConfig      *tls.Config
+
+	// client and httpHeader are used by the HTTP and WebSocket transports
+	client     *http.Client
+	httpHeader http.Header
+
+	// wsPingInterval is how often WebSocket connections are pinged
+	wsPingInterval time.Duration
+
+	// wireLog logs the frames with the client logger at wireLogLevel
+	wireLog         bool
+	wireLogLevel    slog.Level
+	wireLogMaxBytes int
+	wireLogRedact   []string
+
+	stderrHandler    func(line string)
+	stderrInErrors   int
+	structuredStderr bool
+	// stderrTail is shared by the clients a resilient client restarts so
+	// that the stderr of a crashed server can still be read
+	stderrTail *stderrTail
+
+	// protocolVersions lists the versions the client accepts, newest first,
+	// the first one is the one requested
+	protocolVersions []string
+	// protocolVersion is requested in place of the first protocolVersions
+	protocolVersion string
+
+	// restartPolicy makes New return a client restarting crashed servers
+	restartPolicy *ReconnectPolicy
+	// retryPolicy retries the requests failing with a transient error
+	retryPolicy *RetryPolicy
+
+	autoInitialize bool
+	lazyStart      bool
+
+	// toolCache serves ListTools from the tools cached for toolCacheTTL
+	toolCache    bool
+	toolCacheTTL time.Duration
+
+	// keepaliveInterval enables the keepalive pings, each bounded by
+	// keepaliveTimeout
+	keepaliveInterval time.Duration
+	keepaliveTimeout  time.Duration
+	keepaliveFailures int
+	onUnhealthy       func(err error)
+	// killOnUnhealthy kills an unresponsive server so that the resilient
+	// client restarts it
+	killOnUnhealthy bool
+
+	// dispatcher is shared by the clients a resilient client restarts so
+	// that the registered callbacks survive a restart
+	dispatcher *dispatcher
+}
+
+func defaultOptions() options {
+	return options{
+		tracerProvider: noop.NewTracerProvider(),
+		clientInfo:     defaultClientInfo(),
+		methodTimeouts: make(map[string]time.Duration),
+		inheritEnv:     true,
+
+		shutdownTimeout: defaultShutdownTimeout,
+		connectTimeout:  defaultConnectTimeout,
+		wsPingInterval:  defaultWSPingInterval,
+
+		keepaliveFailures: defaultKeepaliveFailures,
+
+		protocolVersions: SupportedProtocolVersions,
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithOtelTracing records a span for every request sent to the server using
+// the given tracer provider, and for the requests the server sends back.
+// The trace context is passed to the server in the _meta of the requests.
+func WithOtelTracing(tp trace.TracerProvider) Option {
+	return func(o *options) {
+		if tp != nil {
+			o.tracerProvider = tp
+		}
+	}
+}
+
+// environ returns the environment of the server process, nil meaning the
+// environment of the current process
+func (o options) environ() []string {
+	if o.inheritEnv && o.env == nil && len(o.extraEnv) == 0 {
+		return nil
+	}
+
+	env := []string{}
+	if o.env != nil {
+		env = append(env, o.env...)
+	} else if o.inheritEnv {
+		env = os.Environ()
+	} else if runtime.GOOS == "windows" {
+		// Nothing starts on Windows without PATH
+		if path, ok := os.LookupEnv("PATH"); ok {
+			env = append(env, "PATH="+path)
+		}
+	}
+
+	keys := make([]string, 0, len(o.extraEnv))
+	for k := range o.extraEnv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		env = append(env, k+"="+o.extraEnv[k])
+	}
+	return env
+}
+
+// WithEnv sets the whole environment of the server process, as "KEY=value"
+// entries, instead of inheriting the one of the current process. Variables
+// given with WithExtraEnv are added on top of it.
+func WithEnv(env []string) Option {
+	return func(o *options) {
+		o.env = append([]string{}, env...)
+	}
+}
+
+// WithExtraEnv adds environment variables to the environment of the server
+// process, the inherited one unless WithEnv or WithInheritEnv(false) is
+// given
+func WithExtraEnv(env map[string]string) Option {
+	return func(o *options) {
+		if o.extraEnv == nil {
+			o.extraEnv = make(map[string]string, len(env))
+		}
+		for k, v := range env {
+			o.extraEnv[k] = v
+		}
+	}
+}
+
+// WithInheritEnv controls whether the server process inherits the
+// environment of the current process, which it does by default. Without it
+// only the variables given with WithExtraEnv are passed, plus PATH on
+// Windows, so that secrets of the host do not leak into the server.
+func WithInheritEnv(inherit bool) Option {
+	return func(o *options) {
+		o.inheritEnv = inherit
+	}
+}
+
+// WithWorkingDir runs the server process in dir
+func WithWorkingDir(dir string) Option {
+	return func(o *options) {
+		o.workingDir = dir
+	}
+}
+
+// WithCmdCustomizer lets fn adjust the server command before it is started,
+// to set SysProcAttr, credentials or resource limits. It runs after the
+// stdio pipes are wired and must not change Stdin, Stdout or Stderr.
+func WithCmdCustomizer(fn func(*exec.Cmd)) Option {
+	return func(o *options) {
+		o.cmdCustomizers = append(o.cmdCustomizers, fn)
+	}
+}
+
+// WithClientInfo sets the name and version the client reports to the
+// server in initialize, "mcpkit" and the version of the library by default
+func WithClientInfo(name, version string) Option {
+	return func(o *options) {
+		o.clientInfo = Implementation{Name: name, Version: version}
+	}
+}
+
+// WithSamplingCapability advertises that the client can answer sampling
+// requests from the server
+func WithSamplingCapability() Option {
+	return func(o *options) {
+		o.capabilities.Sampling = ClientCapabilitiesSampling{}
+	}
+}
+
+// WithRootsCapability advertises that the client can list its roots, and
+// whether it notifies the server when they change
+func WithRootsCapability(listChanged bool) Option {
+	return func(o *options) {
+		o.capabilities.Roots = &ClientCapabilitiesRoots{ListChanged: &listChanged}
+	}
+}
+
+// WithFrameLogging logs every frame read from and written to the server
+// with logger, at debug level
+func WithFrameLogging(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.frameLogger = logger
+	}
+}
+
+// WithWireLog logs every message exchanged with the server with the client
+// logger at level: its direction, method, ID, size and JSON. The JSON is
+// truncated with WithWireLogLimit, and the values of fields such as
+// "authorization", "token" or "password" are redacted, see
+// WithWireLogRedact.
+func WithWireLog(level slog.Level) Option {
+	return func(o *options) {
+		o.wireLog = true
+		o.wireLogLevel = level
+	}
+}
+
+// WithWireLogLimit truncates the JSON logged by WithWireLog and
+// WithFrameLogging after n bytes, 4096 by default. A negative n logs it
+// whole.
+func WithWireLogLimit(n int) Option {
+	return func(o *options) {
+		o.wireLogMaxBytes = n
+	}
+}
+
+// WithWireLogRedact sets the JSON fields whose values are not logged by
+// WithWireLog and WithFrameLogging, compared ignoring case, in place of
+// the default ones. Pass no field to log everything.
+func WithWireLogRedact(fields ...string) Option {
+	return func(o *options) {
+		o.wireLogRedact = append([]string{}, fields...)
+	}
+}
+
+// WithFramer sets the framing of the messages exchanged with the server,
+// newline delimited JSON by default. Use NewHeaderFramer for servers using
+// Content-Length headers, or NewAutoFramer to detect it.
+func WithFramer(f jsonrpc2.Framer) Option {
+	return func(o *options) {
+		o.framer = f
+	}
+}
+
+// WithStderrWriter forwards every line the server writes to stderr to w
+// instead of logging the lines that look like errors
+func WithStderrWriter(w io.Writer) Option {
+	return func(o *options) {
+		o.stderrWriter = w
+	}
+}
+
+// WithStderrHandler calls fn with every line the server writes to stderr,
+// from the goroutine reading it
+func WithStderrHandler(fn func(line string)) Option {
+	return func(o *options) {
+		o.stderrHandler = fn
+	}
+}
+
+// WithStructuredStderrParsing re-emits the lines a server logging with a
+// slog JSON handler writes to stderr with the client logger, at their level
+// and with their message and attributes. Other lines are handled as usual.
+func WithStructuredStderrParsing() Option {
+	return func(o *options) {
+		o.structuredStderr = true
+	}
+}
+
+// WithStderrInErrors appends up to the last n lines of the server stderr to
+// the errors of failed tool calls
+func WithStderrInErrors(n int) Option {
+	return func(o *options) {
+		o.stderrInErrors = n
+	}
+}
+
+// WithRequestTimeout bounds the time the client waits for the response to
+// each request. A timed out request fails with *ErrRequestTimeout.
+func WithRequestTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.requestTimeout = d
+	}
+}
+
+// WithBatchTimeout bounds the time CallBatch waits for all the responses of
+// a batch, overriding the request timeout. A timed out batch fails with
+// *ErrRequestTimeout.
+func WithBatchTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.batchTimeout = d
+	}
+}
+
+// WithMetrics reports every request and notification sent to the server to
+// collector
+func WithMetrics(collector Collector) Option {
+	return func(o *options) {
+		o.metrics = collector
+	}
+}
+
+// WithToolTimeout bounds the requests of the tools category, overriding the
+// request timeout
+func WithToolTimeout(d time.Duration) Option {
+	return withMethodTimeout("tools", d)
+}
+
+// WithResourceTimeout bounds the requests of the resources category,
+// overriding the request timeout
+func WithResourceTimeout(d time.Duration) Option {
+	return withMethodTimeout("resources", d)
+}
+
+func withMethodTimeout(category string, d time.Duration) Option {
+	return func(o *options) {
+		o.methodTimeouts[category] = d
+	}
+}
+
+// WithAutoRestart relaunches and re-initializes the server when its process
+// exits, making up to maxRetries attempts spaced by backoff. Calls that were
+// waiting for a response when the server died fail with ErrServerRestarted,
+// calls that could not be sent are retried once on the new server.
+func WithAutoRestart(maxRetries int, backoff time.Duration) Option {
+	return func(o *options) {
+		o.restartPolicy = &ReconnectPolicy{
+			InitialBackoff: backoff,
+			MaxBackoff:     backoff,
+			MaxRetries:     maxRetries,
+		}
+	}
+}
+
+// WithAutoInitialize lets the first request initialize the client, once
+// even when several are made concurrently, instead of failing until
+// Initialize is called
+func WithAutoInitialize() Option {
+	return func(o *options) {
+		o.autoInitialize = true
+	}
+}
+
+// WithLazyStart starts the server on the first request, such as
+// Initialize, instead of in NewClient, which only checks that the command
+// exists. Clients of servers that may not be used then cost nothing. If
+// the server fails to start, the first request and the following ones
+// fail with the error.
+func WithLazyStart() Option {
+	return func(o *options) {
+		o.lazyStart = true
+	}
+}
+
+// WithToolCache makes ListTools return all the tools at once from a cache,
+// listed again after ttl, or as soon as the server notifies that they
+// changed. A zero ttl keeps them until then.
+func WithToolCache(ttl time.Duration) Option {
+	return func(o *options) {
+		o.toolCache = true
+		o.toolCacheTTL = ttl
+	}
+}
+
+// WithRetry retries the requests failing with a transient error, waiting
+// between attempts as set by policy and never past the context deadline.
+// By default requests are retried when they could not be sent, and the
+// requests without side effects such as lists and ping also when they
+// timed out, see DefaultRetryable. Tool calls that reached the server are
+// not retried, it may have acted on them.
+func WithRetry(policy RetryPolicy) Option {
+	return func(o *options) {
+		o.retryPolicy = &policy
+	}
+}
+
+// WithKeepalive pings the server every interval once initialized, waiting up
+// to timeout for each response. After 3 consecutive failures, or the number
+// given to WithKeepaliveFailures, the client is reported unhealthy and the
+// OnUnhealthy callback is called. With WithAutoRestart the server is then
+// restarted. Without either, the client is closed so that pending and
+// future calls fail instead of hanging.
+func WithKeepalive(interval, timeout time.Duration) Option {
+	return func(o *options) {
+		o.keepaliveInterval = interval
+		o.keepaliveTimeout = timeout
+	}
+}
+
+// WithKeepaliveFailures sets the number of consecutive failed keepalive
+// pings after which the server is unhealthy
+func WithKeepaliveFailures(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.keepaliveFailures = n
+		}
+	}
+}
+
+// WithOnUnhealthy calls fn with the last ping error when the keepalive
+// reports the server unhealthy
+func WithOnUnhealthy(fn func(err error)) Option {
+	return func(o *options) {
+		o.onUnhealthy = fn
+	}
+}
+
+// WithShutdownTimeout sets how long Close waits for the server to exit after
+// closing its stdin, 5 seconds by default. Past it the server is sent
+// SIGTERM, and killed if it is still running 2 seconds later. Windows has no
+// SIGTERM so the server is killed right away.
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.shutdownTimeout = d
+	}
+}
+
+// WithSupportedProtocolVersions sets the protocol versions the client
+// accepts, newest first. The first one is requested in initialize, and a
+// server answering with a version outside the list fails Initialize with
+// *ErrUnsupportedProtocolVersion.
+func WithSupportedProtocolVersions(versions ...string) Option {
+	return func(o *options) {
+		if len(versions) > 0 {
+			o.protocolVersions = append([]string{}, versions...)
+		}
+	}
+}
+
+// WithProtocolVersion sets the protocol version requested in initialize,
+// the latest one by default. Initialize fails if version is not one of the
+// supported versions, which WithSupportedProtocolVersions can extend to try
+// out other revisions of the spec.
+func WithProtocolVersion(version string) Option {
+	return func(o *options) {
+		o.protocolVersion = version
+	}
+}
+
+// WithConnectTimeout bounds connecting to a server reached over the
+// network, 10 seconds by default
+func WithConnectTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.connectTimeout = d
+	}
+}
+
+// WithTLSConfig makes NewTCP connect to the server over TLS with config
+func WithTLSConfig(config *tls.Config) Option {
+	return func(o *options) {
+		o.tlsConfig = config
+	}
+}
+
+// WithHTTPClient sets the HTTP client of the HTTP transports, such as
+// NewSSE, and of the WebSocket handshake, http.DefaultClient by default
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.client = client
+	}
+}
+
+// WithHTTPHeader adds a header to the requests of the HTTP transports and
+// to the WebSocket handshake, such as an Authorization header
+func WithHTTPHeader(key, value string) Option {
+	return func(o *options) {
+		if o.httpHeader == nil {
+			o.httpHeader = make(http.Header)
+		}
+		o.httpHeader.Add(key, value)
+	}
+}
+
+// WithWSPingInterval sets how often WebSocket connections are pinged, 30
+// seconds by default. A ping not answered within the interval closes the
+// connection. Zero disables the pings.
+func WithWSPingInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.wsPingInterval = d
+	}
+}
+
+// httpClient returns the HTTP client of the HTTP transports
+func (o options) httpClient() *http.Client {
+	if o.client != nil {
+		return o.client
+	}
+	return http.DefaultClient
+}
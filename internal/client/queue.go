@@ -0,0 +1,110 @@
+package client
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+)
+
+// Priorities for messages sent by the client itself. Latency sensitive
+// messages use PriorityHigh so they are not stuck behind bulk calls.
+const (
+	PriorityDefault = 0
+	PriorityHigh    = 100
+)
+
+// sendQueue orders the messages waiting to be written to the connection. One
+// message is written at a time, and when the connection is busy the waiting
+// message with the highest priority goes next, in arrival order for equal
+// priorities.
+type sendQueue struct {
+	mu      sync.Mutex
+	busy    bool
+	seq     uint64
+	waiters waiterHeap
+}
+
+type waiter struct {
+	priority int
+	seq      uint64
+	ready    chan struct{}
+	index    int
+}
+
+// acquire blocks until the caller may write to the connection
+func (q *sendQueue) acquire(ctx context.Context, priority int) error {
+	q.mu.Lock()
+	if !q.busy {
+		q.busy = true
+		q.mu.Unlock()
+		return nil
+	}
+	w := &waiter{priority: priority, seq: q.seq, ready: make(chan struct{})}
+	q.seq++
+	heap.Push(&q.waiters, w)
+	q.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		select {
+		case <-w.ready:
+			// Handed the turn while giving up, pass it on
+			q.releaseLocked()
+		default:
+			heap.Remove(&q.waiters, w.index)
+		}
+		return ctx.Err()
+	}
+}
+
+// release hands the connection to the next waiting message
+func (q *sendQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.releaseLocked()
+}
+
+func (q *sendQueue) releaseLocked() {
+	if q.waiters.Len() == 0 {
+		q.busy = false
+		return
+	}
+	w := heap.Pop(&q.waiters).(*waiter)
+	close(w.ready)
+}
+
+type waiterHeap []*waiter
+
+func (h waiterHeap) Len() int { return len(h) }
+
+func (h waiterHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h waiterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *waiterHeap) Push(x interface{}) {
+	w := x.(*waiter)
+	w.index = len(*h)
+	*h = append(*h, w)
+}
+
+func (h *waiterHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	w := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return w
+}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// queued returns the number of messages waiting for their turn in q
+func queued(q *sendQueue) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.waiters.Len()
+}
+
+func TestSendQueueOrder(t *testing.T) {
+	ctx := testContext(t)
+	var q sendQueue
+	if err := q.acquire(ctx, PriorityDefault); err != nil {
+		t.Fatal(err)
+	}
+
+	order := make(chan string, 5)
+	enqueue := func(ctx context.Context, name string, priority int) {
+		n := queued(&q)
+		go func() {
+			if err := q.acquire(ctx, priority); err != nil {
+				return
+			}
+			order <- name
+			q.release()
+		}()
+		eventually(t, func() bool { return queued(&q) == n+1 }, "%s not queued", name)
+	}
+	enqueue(ctx, "call1", PriorityDefault)
+	enqueue(ctx, "call2", PriorityDefault)
+	cancelled, cancel := context.WithCancel(ctx)
+	enqueue(cancelled, "cancelled", PriorityHigh)
+	enqueue(ctx, "call3", PriorityDefault)
+	enqueue(ctx, "ping", PriorityHigh)
+
+	// A message giving up leaves the queue
+	cancel()
+	eventually(t, func() bool { return queued(&q) == 4 }, "cancelled message still queued")
+	q.release()
+
+	var got []string
+	for range 4 {
+		got = append(got, <-order)
+	}
+	want := []string{"ping", "call1", "call2", "call3"}
+	if !slices.Equal(got, want) {
+		t.Errorf("order = %v, want %v", got, want)
+	}
+}
+
+func TestPingJumpsQueuedCalls(t *testing.T) {
+	s := newFakeServer()
+	s.handle("tools/call", func(context.Context, json.RawMessage) (interface{}, error) {
+		return textResult("ok"), nil
+	})
+	c := newInitializedClient(t, s)
+	ctx := testContext(t)
+
+	// Hold the connection, as while writing a large message
+	if err := c.queue.acquire(ctx, PriorityDefault); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.CallTool(ctx, "bulk", nil); err != nil {
+				t.Errorf("CallTool: %v", err)
+			}
+		}()
+		eventually(t, func() bool { return queued(&c.queue) == i+1 }, "call %d not queued", i)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := c.Ping(ctx); err != nil {
+			t.Errorf("Ping: %v", err)
+		}
+	}()
+	eventually(t, func() bool { return queued(&c.queue) == 4 }, "ping not queued")
+	c.queue.release()
+	wg.Wait()
+
+	var sent []string
+	for _, method := range s.methods() {
+		if method == "tools/call" || method == "ping" {
+			sent = append(sent, method)
+		}
+	}
+	want := []string{"ping", "tools/call", "tools/call", "tools/call"}
+	if !slices.Equal(sent, want) {
+		t.Errorf("sent %v, want %v", sent, want)
+	}
+}
@@ -0,0 +1,236 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/jsonrpc2"
+)
+
+// frameRecord is one line of a recording, holding a message read from
+// ("in") or written to ("out") the server. Raw is the hex encoded JSON of
+// the message, without the framing.
+type frameRecord struct {
+	Dir string `json:"dir"`
+	Ts  string `json:"ts"`
+	Raw string `json:"raw"`
+}
+
+// NewRecordingFramer returns a Framer that reads and writes with base and
+// records every message as a JSON Lines record to w:
+//
+//	{"dir":"in","ts":"2025-01-02T15:04:05.999999999Z","raw":"7b226a..."}
+//
+// The recording can be replayed with NewReplayFramer.
+func NewRecordingFramer(base jsonrpc2.Framer, w io.Writer) jsonrpc2.Framer {
+	return &recordingFramer{base: base, w: w}
+}
+
+type recordingFramer struct {
+	base jsonrpc2.Framer
+
+	mu sync.Mutex // serializes the records of the reader and the writer
+	w  io.Writer
+}
+
+func (f *recordingFramer) Reader(r io.Reader) jsonrpc2.Reader {
+	return &recordingReader{framer: f, base: f.base.Reader(r)}
+}
+
+func (f *recordingFramer) Writer(w io.Writer) jsonrpc2.Writer {
+	return &recordingWriter{framer: f, base: f.base.Writer(w)}
+}
+
+func (f *recordingFramer) record(dir string, msg jsonrpc2.Message) error {
+	data, err := jsonrpc2.EncodeMessage(msg)
+	if err != nil {
+		return fmt.Errorf("marshaling message: %w", err)
+	}
+
+	line, err := json.Marshal(frameRecord{
+		Dir: dir,
+		Ts:  time.Now().UTC().Format(time.RFC3339Nano),
+		Raw: hex.EncodeToString(data),
+	})
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, err = f.w.Write(line)
+	return err
+}
+
+type recordingReader struct {
+	framer *recordingFramer
+	base   jsonrpc2.Reader
+}
+
+func (r *recordingReader) Read(ctx context.Context) (jsonrpc2.Message, int64, error) {
+	msg, n, err := r.base.Read(ctx)
+	if err != nil {
+		return msg, n, err
+	}
+	if err := r.framer.record("in", msg); err != nil {
+		return nil, n, fmt.Errorf("failed to record frame: %w", err)
+	}
+	return msg, n, nil
+}
+
+type recordingWriter struct {
+	framer *recordingFramer
+	base   jsonrpc2.Writer
+}
+
+func (w *recordingWriter) Write(ctx context.Context, msg jsonrpc2.Message) (int64, error) {
+	n, err := w.base.Write(ctx, msg)
+	if err != nil {
+		return n, err
+	}
+	if err := w.framer.record("out", msg); err != nil {
+		return n, fmt.Errorf("failed to record frame: %w", err)
+	}
+	return n, nil
+}
+
+func (w *recordingWriter) writeBatch(ctx context.Context, msgs []jsonrpc2.Message) (int64, error) {
+	n, err := writeBatch(ctx, w.base, msgs)
+	if err != nil {
+		return n, err
+	}
+	for _, msg := range msgs {
+		if err := w.framer.record("out", msg); err != nil {
+			return n, fmt.Errorf("failed to record frame: %w", err)
+		}
+	}
+	return n, nil
+}
+
+// NewReplayFramer returns a Framer replaying a recording made by
+// NewRecordingFramer. Its reader returns the "in" messages of the recording
+// in order, each one once the messages written before it in the recording
+// have been written, and io.EOF at the end of the recording. Written
+// messages are discarded, as is anything read from the live stream, which
+// only ends the replay when it is closed.
+//
+// A replay framer replays its recording once, for a single connection.
+func NewReplayFramer(r io.Reader) jsonrpc2.Framer {
+	return &replayFramer{
+		src:     r,
+		written: make(chan struct{}),
+	}
+}
+
+type replayFramer struct {
+	src io.Reader
+
+	mu sync.Mutex
+	// writes counts the messages written so far, written is closed and
+	// replaced on every write
+	writes  int
+	written chan struct{}
+}
+
+func (f *replayFramer) Reader(r io.Reader) jsonrpc2.Reader {
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		_, _ = io.Copy(io.Discard, r)
+	}()
+	return &replayReader{framer: f, in: bufio.NewReader(f.src), closed: closed}
+}
+
+func (f *replayFramer) Writer(w io.Writer) jsonrpc2.Writer {
+	return replayWriter{framer: f}
+}
+
+// waitWrites blocks until n messages have been written
+func (f *replayFramer) waitWrites(ctx context.Context, closed <-chan struct{}, n int) error {
+	for {
+		f.mu.Lock()
+		writes, written := f.writes, f.written
+		f.mu.Unlock()
+
+		if writes >= n {
+			return nil
+		}
+
+		select {
+		case <-written:
+		case <-closed:
+			return io.EOF
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+type replayReader struct {
+	framer *replayFramer
+	in     *bufio.Reader
+	closed <-chan struct{}
+	// outs counts the "out" records seen so far
+	outs int
+}
+
+func (r *replayReader) Read(ctx context.Context) (jsonrpc2.Message, int64, error) {
+	for {
+		line, err := r.in.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			return nil, 0, io.EOF
+		}
+		if err != nil && err != io.EOF {
+			return nil, 0, fmt.Errorf("failed to read recording: %w", err)
+		}
+
+		var rec frameRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return nil, 0, fmt.Errorf("invalid record: %w", err)
+		}
+		if rec.Dir == "out" {
+			r.outs++
+			continue
+		}
+		if rec.Dir != "in" {
+			return nil, 0, fmt.Errorf("invalid record direction %q", rec.Dir)
+		}
+
+		data, err := hex.DecodeString(rec.Raw)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid record data: %w", err)
+		}
+		if err := r.framer.waitWrites(ctx, r.closed, r.outs); err != nil {
+			return nil, 0, err
+		}
+
+		msg, err := decodeMessage(data)
+		return msg, int64(len(data)), err
+	}
+}
+
+type replayWriter struct {
+	framer *replayFramer
+}
+
+func (w replayWriter) Write(ctx context.Context, msg jsonrpc2.Message) (int64, error) {
+	data, err := jsonrpc2.EncodeMessage(msg)
+	if err != nil {
+		return 0, fmt.Errorf("marshaling message: %w", err)
+	}
+
+	w.framer.mu.Lock()
+	w.framer.writes++
+	close(w.framer.written)
+	w.framer.written = make(chan struct{})
+	w.framer.mu.Unlock()
+
+	return int64(len(data)), nil
+}
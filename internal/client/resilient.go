@@ -0,0 +1,601 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// ReconnectPolicy controls how a resilient client respawns a crashed server
+type ReconnectPolicy struct {
+	// InitialBackoff is the delay before the first restart attempt
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the exponential growth of the delay between attempts
+	MaxBackoff time.Duration
+
+	// MaxRetries is the number of consecutive restart attempts before the
+	// client gives up. Zero or less means retry forever.
+	MaxRetries int
+
+	// RetryInFlight resends a request that was waiting for its response when
+	// the server died. When false such requests fail with ErrServerRestarted
+	// since the server may already have acted on them.
+	RetryInFlight bool
+
+	// OnReconnect is called after the server has been restarted, and
+	// re-initialized if Initialize had been called before the crash
+	OnReconnect func(attempt int, info *ServerInfo)
+}
+
+// DefaultReconnectPolicy returns the policy used when none is configured
+func DefaultReconnectPolicy() ReconnectPolicy {
+	return ReconnectPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+		MaxRetries:     5,
+		RetryInFlight:  true,
+	}
+}
+
+// backoff returns the delay before the given attempt (starting at 1)
+func (p ReconnectPolicy) backoff(attempt int) time.Duration {
+	return jitteredBackoff(p.InitialBackoff, p.MaxBackoff, attempt)
+}
+
+// jitteredBackoff returns the delay before the given attempt (starting at
+// 1), using exponential growth from initial up to max with jitter to avoid
+// retry storms
+func jitteredBackoff(initial, max time.Duration, attempt int) time.Duration {
+	d := initial
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if max > 0 && d > max {
+		d = max
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int64N(int64(half)+1))
+}
+
+var (
+	// ErrReconnectFailed is returned by a resilient client once the server
+	// could not be restarted within the retry limit
+	ErrReconnectFailed = errors.New("failed to restart MCP server")
+
+	// ErrServerRestarted is returned for a request that was waiting for its
+	// response when the server died and was restarted
+	ErrServerRestarted = errors.New("MCP server restarted")
+)
+
+type resilientClient struct {
+	// ctx stops the supervisor, the servers are started with it so that
+	// the ones started while closing do not outlive the client
+	ctx       context.Context
+	cancelFn  context.CancelFunc
+	logger    *slog.Logger
+	serverCmd string
+	args      []string
+	opts      options
+	policy    ReconnectPolicy
+	handler   *dispatcher
+
+	mu sync.Mutex
+	// current is the live client, nil while a restart is in progress
+	current *client
+	// ready is closed once current is usable or the client failed for good
+	ready chan struct{}
+	// err is set when the client cannot recover anymore
+	err error
+	// initialized records whether Initialize must be replayed on restart
+	initialized bool
+	exit        *errNotifier
+}
+
+// NewResilient creates a client that restarts the server command whenever
+// the process exits unexpectedly, re-running Initialize when it had already
+// been called so that callers can keep using it transparently.
+func NewResilient(
+	ctxParent context.Context,
+	logger *slog.Logger,
+	serverCmd string,
+	args []string,
+	policy ReconnectPolicy,
+	opts ...Option,
+) (Client, error) {
+	return newResilient(ctxParent, logger, serverCmd, args, policy, newOptions(opts))
+}
+
+func newResilient(
+	ctxParent context.Context,
+	logger *slog.Logger,
+	serverCmd string,
+	args []string,
+	policy ReconnectPolicy,
+	o options,
+) (*resilientClient, error) {
+	// Share the dispatcher between restarts so that registered callbacks
+	// keep working on the new server
+	handler := newDispatcher(logger, o.tracerProvider.Tracer(tracerName))
+	o.dispatcher = handler
+	o.stderrTail = newStderrTail()
+	o.restartPolicy = nil
+	o.killOnUnhealthy = true
+	ctx, cancel := context.WithCancel(ctxParent)
+	c, err := newClient(ctx, logger, serverCmd, args, o)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	r := &resilientClient{
+		ctx:       ctx,
+		cancelFn:  cancel,
+		logger:    logger,
+		serverCmd: serverCmd,
+		args:      args,
+		opts:      o,
+		policy:    policy,
+		handler:   handler,
+		current:   c,
+		ready:     make(chan struct{}),
+		exit:      newErrNotifier(),
+	}
+	close(r.ready)
+
+	go r.supervise(r.current)
+	return r, nil
+}
+
+// supervise waits for the given client to die and restarts the server
+func (r *resilientClient) supervise(c *client) {
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-c.ctx.Done():
+		}
+
+		r.mu.Lock()
+		if r.ctx.Err() != nil {
+			r.mu.Unlock()
+			return
+		}
+		r.current = nil
+		r.ready = make(chan struct{})
+		r.mu.Unlock()
+
+		r.logger.Warn("MCP server exited, restarting", "cmd", r.serverCmd)
+		next, err := r.restart()
+
+		r.mu.Lock()
+		if r.ctx.Err() != nil {
+			// Close ran during the restart and could not see next
+			r.mu.Unlock()
+			if next != nil {
+				next.Close()
+			}
+			return
+		}
+		if err != nil {
+			r.err = err
+		} else {
+			r.current = next
+		}
+		close(r.ready)
+		r.mu.Unlock()
+
+		if err != nil {
+			r.logger.Error("giving up restarting MCP server", "error", err)
+			r.exit.fire(err)
+			return
+		}
+		c = next
+	}
+}
+
+func (r *resilientClient) restart() (*client, error) {
+	var lastErr error
+	for attempt := 1; r.policy.MaxRetries <= 0 || attempt <= r.policy.MaxRetries; attempt++ {
+		timer := time.NewTimer(r.policy.backoff(attempt))
+		select {
+		case <-r.ctx.Done():
+			timer.Stop()
+			return nil, r.ctx.Err()
+		case <-timer.C:
+		}
+
+		c, info, err := r.spawn()
+		if err != nil {
+			lastErr = err
+			r.logger.Warn("restart attempt failed", "attempt", attempt, "error", err)
+			continue
+		}
+
+		r.logger.Info("MCP server restarted", "attempt", attempt)
+		if r.policy.OnReconnect != nil {
+			r.policy.OnReconnect(attempt, info)
+		}
+		return c, nil
+	}
+	return nil, fmt.Errorf("%w: %w", ErrReconnectFailed, lastErr)
+}
+
+func (r *resilientClient) spawn() (*client, *ServerInfo, error) {
+	c, err := newClient(r.ctx, r.logger, r.serverCmd, r.args, r.opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r.mu.Lock()
+	initialized := r.initialized
+	r.mu.Unlock()
+	if !initialized {
+		return c, nil, nil
+	}
+
+	info, err := c.Initialize(r.ctx)
+	if err != nil {
+		c.Close()
+		return nil, nil, err
+	}
+
+	// Subscriptions are server state, renew them on the new server
+	for _, uri := range r.handler.subscribedURIs() {
+		params := SubscribeRequestParams{Uri: uri}
+		if err := c.call(r.ctx, "resources/subscribe", params, nil); err != nil {
+			r.logger.Warn("failed to renew subscription", "uri", uri, "error", err)
+		}
+	}
+	return c, info, nil
+}
+
+// acquire returns the live client, waiting for a pending restart to finish
+func (r *resilientClient) acquire(ctx context.Context) (*client, error) {
+	for {
+		r.mu.Lock()
+		c, ready, err := r.current, r.ready, r.err
+		closed := r.ctx.Err() != nil
+		r.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		if closed {
+			return nil, ErrClientClosed
+		}
+		if c != nil {
+			return c, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-r.ctx.Done():
+			return nil, ErrClientClosed
+		case <-ready:
+		}
+	}
+}
+
+// retry runs fn against the live client, running it once more on the
+// restarted server when the process died before the call completed
+func retry[T any](
+	r *resilientClient,
+	ctx context.Context,
+	fn func(c *client) (T, error),
+) (T, error) {
+	c, err := r.acquire(ctx)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	v, err := fn(c)
+	if err == nil || c.ctx.Err() == nil || r.ctx.Err() != nil {
+		return v, err
+	}
+	var interrupted *interruptedError
+	if errors.As(err, &interrupted) && !r.policy.RetryInFlight {
+		return v, fmt.Errorf("%w: %w", ErrServerRestarted, err)
+	}
+
+	c, err = r.acquire(ctx)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return fn(c)
+}
+
+func (r *resilientClient) Initialize(ctx context.Context) (*ServerInfo, error) {
+	info, err := retry(r, ctx, func(c *client) (*ServerInfo, error) {
+		return c.Initialize(ctx)
+	})
+	if err == nil {
+		r.mu.Lock()
+		r.initialized = true
+		r.mu.Unlock()
+	}
+	return info, err
+}
+
+func (r *resilientClient) ReinitializeCapabilities(ctx context.Context) error {
+	_, err := retry(r, ctx, func(c *client) (struct{}, error) {
+		return struct{}{}, c.ReinitializeCapabilities(ctx)
+	})
+	return err
+}
+
+func (r *resilientClient) Ping(ctx context.Context) error {
+	_, err := retry(r, ctx, func(c *client) (struct{}, error) {
+		return struct{}{}, c.Ping(ctx)
+	})
+	return err
+}
+
+func (r *resilientClient) SetLevel(ctx context.Context, level LoggingLevel) error {
+	_, err := retry(r, ctx, func(c *client) (struct{}, error) {
+		return struct{}{}, c.SetLevel(ctx, level)
+	})
+	return err
+}
+
+func (r *resilientClient) OnLogMessage(fn func(LoggingMessageNotification)) func() {
+	return r.handler.onLogMessage(fn)
+}
+
+func (r *resilientClient) OnNotification(method string, fn NotificationHandler) func() {
+	return r.handler.onNotification(method, fn)
+}
+
+func (r *resilientClient) SetSamplingHandler(fn SamplingHandler) {
+	r.handler.setSamplingHandler(fn)
+}
+
+func (r *resilientClient) SetRoots(roots []Root) error {
+	if err := validateRoots(roots); err != nil {
+		return err
+	}
+	r.handler.setRoots(roots)
+
+	// A restarted server lists the roots again, only the live one needs to
+	// be told about the change
+	r.mu.Lock()
+	c := r.current
+	r.mu.Unlock()
+	if c == nil || c.ctx.Err() != nil {
+		return nil
+	}
+	return c.notifyRootsChanged()
+}
+
+func (r *resilientClient) Healthy() bool {
+	r.mu.Lock()
+	c := r.current
+	r.mu.Unlock()
+	return c != nil && c.Healthy()
+}
+
+// Supports reports whether the current server advertised capability
+func (r *resilientClient) Supports(capability string) bool {
+	r.mu.Lock()
+	c := r.current
+	r.mu.Unlock()
+	return c != nil && c.Supports(capability)
+}
+
+// Tool returns the tool called name from the tools cached for the current
+// server
+func (r *resilientClient) Tool(name string) (*Tool, bool) {
+	r.mu.Lock()
+	c := r.current
+	r.mu.Unlock()
+	if c == nil {
+		return nil, false
+	}
+	return c.Tool(name)
+}
+
+// Stats returns the traffic of the current server, counted since it was
+// last started
+func (r *resilientClient) Stats() ConnectionStats {
+	r.mu.Lock()
+	c := r.current
+	r.mu.Unlock()
+	if c == nil {
+		return ConnectionStats{}
+	}
+	return c.Stats()
+}
+
+// Err returns a channel receiving ErrReconnectFailed if the server could
+// not be restarted, the servers that are restarted are not reported
+func (r *resilientClient) Err() <-chan error {
+	return r.exit.ch
+}
+
+func (r *resilientClient) LastPong() time.Time {
+	r.mu.Lock()
+	c := r.current
+	r.mu.Unlock()
+	if c == nil {
+		return time.Time{}
+	}
+	return c.LastPong()
+}
+
+func (r *resilientClient) SetRootsListHandler(fn func(ctx context.Context) ([]Root, error)) {
+	r.handler.setRootsListHandler(fn)
+}
+
+func (r *resilientClient) CallBatch(
+	ctx context.Context,
+	reqs []BatchRequest,
+) ([]BatchResponse, error) {
+	return retry(r, ctx, func(c *client) ([]BatchResponse, error) {
+		return c.CallBatch(ctx, reqs)
+	})
+}
+
+func (r *resilientClient) StderrTail() []string {
+	return r.opts.stderrTail.last(-1)
+}
+
+type page[T any] struct {
+	items  []T
+	cursor *string
+}
+
+func (r *resilientClient) ListTools(ctx context.Context, cursor *string) ([]Tool, *string, error) {
+	p, err := retry(r, ctx, func(c *client) (page[Tool], error) {
+		items, next, err := c.ListTools(ctx, cursor)
+		return page[Tool]{items, next}, err
+	})
+	return p.items, p.cursor, err
+}
+
+func (r *resilientClient) ListResources(
+	ctx context.Context,
+	cursor *string,
+) ([]Resource, *string, error) {
+	p, err := retry(r, ctx, func(c *client) (page[Resource], error) {
+		items, next, err := c.ListResources(ctx, cursor)
+		return page[Resource]{items, next}, err
+	})
+	return p.items, p.cursor, err
+}
+
+func (r *resilientClient) Subscribe(
+	ctx context.Context,
+	uri string,
+	fn func(uri string),
+) error {
+	_, err := retry(r, ctx, func(c *client) (struct{}, error) {
+		return struct{}{}, c.Subscribe(ctx, uri, fn)
+	})
+	return err
+}
+
+func (r *resilientClient) Unsubscribe(ctx context.Context, uri string) error {
+	_, err := retry(r, ctx, func(c *client) (struct{}, error) {
+		return struct{}{}, c.Unsubscribe(ctx, uri)
+	})
+	return err
+}
+
+func (r *resilientClient) ListPrompts(
+	ctx context.Context,
+	cursor *string,
+) ([]Prompt, *string, error) {
+	p, err := retry(r, ctx, func(c *client) (page[Prompt], error) {
+		items, next, err := c.ListPrompts(ctx, cursor)
+		return page[Prompt]{items, next}, err
+	})
+	return p.items, p.cursor, err
+}
+
+func (r *resilientClient) GetPrompt(
+	ctx context.Context,
+	name string,
+	args map[string]string,
+) (*GetPromptResult, error) {
+	return retry(r, ctx, func(c *client) (*GetPromptResult, error) {
+		return c.GetPrompt(ctx, name, args)
+	})
+}
+
+func (r *resilientClient) Complete(
+	ctx context.Context,
+	ref CompletionRef,
+	argName, argValue string,
+) (*CompletionResult, error) {
+	return retry(r, ctx, func(c *client) (*CompletionResult, error) {
+		return c.Complete(ctx, ref, argName, argValue)
+	})
+}
+
+func (r *resilientClient) ListResourceTemplates(
+	ctx context.Context,
+	cursor *string,
+) ([]ResourceTemplate, *string, error) {
+	p, err := retry(r, ctx, func(c *client) (page[ResourceTemplate], error) {
+		items, next, err := c.ListResourceTemplates(ctx, cursor)
+		return page[ResourceTemplate]{items, next}, err
+	})
+	return p.items, p.cursor, err
+}
+
+func (r *resilientClient) ReadResource(ctx context.Context, uri string) ([]ResourceContent, error) {
+	return retry(r, ctx, func(c *client) ([]ResourceContent, error) {
+		return c.ReadResource(ctx, uri)
+	})
+}
+
+func (r *resilientClient) ReadResourceRaw(ctx context.Context, uri string) (*[]interface{}, error) {
+	return retry(r, ctx, func(c *client) (*[]interface{}, error) {
+		return c.ReadResourceRaw(ctx, uri)
+	})
+}
+
+func (r *resilientClient) CallTool(
+	ctx context.Context,
+	name string,
+	args interface{},
+	opts ...CallOption,
+) (*CallToolResult, error) {
+	return retry(r, ctx, func(c *client) (*CallToolResult, error) {
+		return c.CallTool(ctx, name, args, opts...)
+	})
+}
+
+func (r *resilientClient) CallToolValidated(
+	ctx context.Context,
+	name string,
+	args interface{},
+	opts ...CallOption,
+) (*CallToolResult, error) {
+	return retry(r, ctx, func(c *client) (*CallToolResult, error) {
+		return c.CallToolValidated(ctx, name, args, opts...)
+	})
+}
+
+// DrainAndClose lets the current server, if any, answer the requests in
+// flight before closing the client. The supervisor is stopped afterwards,
+// stopping it first would cancel the requests of the server.
+func (r *resilientClient) DrainAndClose(ctx context.Context) error {
+	r.mu.Lock()
+	c := r.current
+	r.mu.Unlock()
+	if c == nil {
+		return r.Close()
+	}
+
+	drainErr := c.drain.wait(ctx)
+	if drainErr != nil {
+		r.logger.Warn("closing with requests in flight", "error", drainErr)
+		drainErr = fmt.Errorf("requests still in flight: %w", drainErr)
+	}
+	return errors.Join(drainErr, r.Close())
+}
+
+// Close stops the supervisor and shuts down the current server, if any
+func (r *resilientClient) Close() error {
+	r.mu.Lock()
+	r.cancelFn()
+	c := r.current
+	r.current = nil
+	r.mu.Unlock()
+	r.exit.fire(nil)
+
+	if c != nil {
+		return c.Close()
+	}
+	return nil
+}
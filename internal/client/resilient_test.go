@@ -0,0 +1,122 @@
+package client
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestResilientCloseDuringRestart(t *testing.T) {
+	ctx := testContext(t)
+	cmd, args := serverCommand(t, "default")
+
+	var r *resilientClient
+	closed := make(chan error, 1)
+	policy := ReconnectPolicy{
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     10 * time.Millisecond,
+		MaxRetries:     3,
+		// Close lands between the restart and the supervisor taking the
+		// new server, which Close cannot see
+		OnReconnect: func(int, *ServerInfo) { closed <- r.Close() },
+	}
+	r, err := newResilient(ctx, testLogger(), cmd, args, policy, newOptions(nil))
+	if err != nil {
+		t.Fatalf("newResilient: %v", err)
+	}
+	if _, err := r.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+
+	if _, err := r.CallTool(ctx, "exit", nil); err == nil {
+		t.Fatal("CallTool(exit) succeeded")
+	}
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("the server was not restarted")
+	}
+
+	waitNoGoroutine(t, "(*client).monitorErrors")
+	if _, err := r.acquire(ctx); err != ErrClientClosed {
+		t.Fatalf("acquire after Close = %v, want ErrClientClosed", err)
+	}
+}
+
+func TestResilientRestartsCrashedServer(t *testing.T) {
+	ctx := testContext(t)
+	cmd, args := serverCommand(t, "default")
+
+	reconnected := make(chan int, 1)
+	policy := ReconnectPolicy{
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     10 * time.Millisecond,
+		MaxRetries:     3,
+		OnReconnect:    func(attempt int, _ *ServerInfo) { reconnected <- attempt },
+	}
+	c, err := NewResilient(ctx, testLogger(), cmd, args, policy)
+	if err != nil {
+		t.Fatalf("NewResilient: %v", err)
+	}
+	defer c.Close()
+	if _, err := c.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+
+	_, err = c.CallTool(ctx, "exit", nil)
+	if !errors.Is(err, ErrServerRestarted) {
+		t.Fatalf("CallTool(exit) = %v, want ErrServerRestarted", err)
+	}
+	select {
+	case <-reconnected:
+	case <-ctx.Done():
+		t.Fatal("OnReconnect was not called")
+	}
+	if err := c.Ping(ctx); err != nil {
+		t.Fatalf("Ping after restart: %v", err)
+	}
+}
+
+func TestResilientConcurrentClose(t *testing.T) {
+	ctx := testContext(t)
+	cmd, args := serverCommand(t, "default")
+	r, err := newResilient(ctx, testLogger(), cmd, args, ReconnectPolicy{MaxRetries: 3}, newOptions(nil))
+	if err != nil {
+		t.Fatalf("newResilient: %v", err)
+	}
+	if _, err := r.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+
+	called := make(chan error, 1)
+	go func() {
+		_, err := r.CallTool(ctx, "sleep", map[string]interface{}{"ms": 200})
+		called <- err
+	}()
+	// Let the call reach the server
+	time.Sleep(50 * time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.Close()
+		}()
+	}
+	wg.Wait()
+
+	if err := <-called; !errors.Is(err, ErrClientClosed) {
+		t.Errorf("CallTool in flight = %v, want ErrClientClosed", err)
+	}
+	if _, err := r.acquire(ctx); err != ErrClientClosed {
+		t.Errorf("acquire after Close = %v, want ErrClientClosed", err)
+	}
+	if err := r.Ping(ctx); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Ping after Close = %v, want ErrClientClosed", err)
+	}
+}
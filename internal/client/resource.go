@@ -0,0 +1,72 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// ResourceContent is one item of a resources/read result. Text resources
+// have Text set, binary resources have Blob holding the decoded data.
+type ResourceContent struct {
+	// The URI of this resource.
+	Uri string
+
+	// The MIME type of this resource, if known.
+	MimeType *string
+
+	// The text of the item, nil for binary resources.
+	Text *string
+
+	// The binary data of the item, decoded from base64.
+	Blob []byte
+}
+
+// IsBlob reports whether the content holds binary data
+func (r ResourceContent) IsBlob() bool {
+	return r.Text == nil
+}
+
+type resourceContentJSON struct {
+	Uri      string  `json:"uri"`
+	MimeType *string `json:"mimeType,omitempty"`
+	Text     *string `json:"text,omitempty"`
+	Blob     *string `json:"blob,omitempty"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (r *ResourceContent) UnmarshalJSON(b []byte) error {
+	var raw resourceContentJSON
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if raw.Uri == "" {
+		return fmt.Errorf("field uri in ResourceContent: required")
+	}
+
+	content := ResourceContent{Uri: raw.Uri, MimeType: raw.MimeType}
+	switch {
+	case raw.Text != nil:
+		content.Text = raw.Text
+	case raw.Blob != nil:
+		blob, err := base64.StdEncoding.DecodeString(*raw.Blob)
+		if err != nil {
+			return fmt.Errorf("field blob in ResourceContent: %w", err)
+		}
+		content.Blob = blob
+	default:
+		return fmt.Errorf("ResourceContent for %s has neither text nor blob", raw.Uri)
+	}
+	*r = content
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (r ResourceContent) MarshalJSON() ([]byte, error) {
+	raw := resourceContentJSON{Uri: r.Uri, MimeType: r.MimeType, Text: r.Text}
+	if r.Text == nil {
+		blob := base64.StdEncoding.EncodeToString(r.Blob)
+		raw.Blob = &blob
+	}
+	return json.Marshal(raw)
+}
@@ -0,0 +1,128 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// RetryPolicy controls how requests failing with a transient error are
+// retried, see WithRetry
+type RetryPolicy struct {
+	// InitialBackoff is the delay before the first retry, the one of
+	// DefaultRetryPolicy when zero
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the exponential growth of the delay between retries,
+	// the one of DefaultRetryPolicy when zero
+	MaxBackoff time.Duration
+
+	// MaxRetries is the number of retries after the first attempt. Zero or
+	// less means retrying until the context is done.
+	MaxRetries int
+
+	// Retryable decides whether a request of method failing with err is
+	// retried. Nil means DefaultRetryable.
+	Retryable func(method string, err error) bool
+}
+
+// DefaultRetryPolicy returns a policy retrying up to 3 times
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+		MaxRetries:     3,
+	}
+}
+
+// backoff returns the delay before the given retry (starting at 1). The
+// backoffs left to zero are the ones of DefaultRetryPolicy, so that the
+// retries are never sent back to back.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	def := DefaultRetryPolicy()
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = def.InitialBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = max(def.MaxBackoff, p.InitialBackoff)
+	}
+	return jitteredBackoff(p.InitialBackoff, p.MaxBackoff, attempt)
+}
+
+// ErrNotSent is matched by the errors of requests that could not be written
+// to the server, which therefore never saw them
+var ErrNotSent = errors.New("request not sent")
+
+// sendError is the error of a message that could not be written
+type sendError struct {
+	err error
+}
+
+func (e *sendError) Error() string {
+	return e.err.Error()
+}
+
+func (e *sendError) Unwrap() []error {
+	return []error{ErrNotSent, e.err}
+}
+
+// idempotentMethods are the methods that can be sent again without
+// side effects on the server
+var idempotentMethods = map[string]bool{
+	"ping":                     true,
+	"tools/list":               true,
+	"resources/list":           true,
+	"resources/templates/list": true,
+	"resources/read":           true,
+	"prompts/list":             true,
+	"prompts/get":              true,
+}
+
+// DefaultRetryable retries the requests that were not sent. Requests that
+// only read from the server, such as lists and ping, are also retried when
+// the server did not answer within the request timeout.
+func DefaultRetryable(method string, err error) bool {
+	if errors.Is(err, ErrNotSent) {
+		return true
+	}
+	var timeout *ErrRequestTimeout
+	return idempotentMethods[method] && errors.As(err, &timeout)
+}
+
+// withRetries runs the request sent by send, retrying it with the retry
+// policy of the client for as long as ctx allows
+func (c *client) withRetries(ctx context.Context, method string, send func() error) error {
+	if c.retryPolicy == nil {
+		return send()
+	}
+	p := c.retryPolicy
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = DefaultRetryable
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := send()
+		if err == nil || !retryable(method, err) ||
+			(p.MaxRetries > 0 && attempt > p.MaxRetries) {
+			return err
+		}
+		delay := p.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return err
+		}
+		c.logger.Debug("retrying request",
+			"method", method, "attempt", attempt, "delay", delay, "error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-c.ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
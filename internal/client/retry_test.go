@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/jsonrpc2"
+)
+
+// flakyServer fails the first two requests of every method, by never
+// answering tools/list and with an internal error for tools/call
+func flakyServer() *fakeServer {
+	var lists, calls atomic.Int32
+
+	s := newFakeServer()
+	s.handle("tools/list", func(context.Context, json.RawMessage) (interface{}, error) {
+		if lists.Add(1) <= 2 {
+			// Left unanswered, without holding up the other requests
+			return nil, jsonrpc2.ErrAsyncResponse
+		}
+		return ListToolsResult{Tools: []Tool{{Name: "echo"}}}, nil
+	})
+	s.handle("tools/call", func(context.Context, json.RawMessage) (interface{}, error) {
+		if calls.Add(1) <= 2 {
+			return nil, jsonrpc2.NewError(CodeInternalError, "busy")
+		}
+		return textResult("ok"), nil
+	})
+	return s
+}
+
+func TestRetry(t *testing.T) {
+	policy := RetryPolicy{
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+		MaxRetries:     3,
+	}
+
+	t.Run("idempotent", func(t *testing.T) {
+		s := flakyServer()
+		c := newInitializedClient(t, s, WithRetry(policy), WithRequestTimeout(50*time.Millisecond))
+
+		tools, _, err := c.ListTools(testContext(t), nil)
+		if err != nil || len(tools) != 1 {
+			t.Fatalf("ListTools = %v, %v, want the tools on the third attempt", tools, err)
+		}
+		if n := len(s.requests("tools/list")); n != 3 {
+			t.Errorf("server saw %d tools/list, want 3", n)
+		}
+	})
+
+	t.Run("too few retries", func(t *testing.T) {
+		s := flakyServer()
+		once := policy
+		once.MaxRetries = 1
+		c := newInitializedClient(t, s, WithRetry(once), WithRequestTimeout(50*time.Millisecond))
+
+		_, _, err := c.ListTools(testContext(t), nil)
+		var timeout *ErrRequestTimeout
+		if !errors.As(err, &timeout) {
+			t.Fatalf("ListTools = %v, want *ErrRequestTimeout", err)
+		}
+		if n := len(s.requests("tools/list")); n != 2 {
+			t.Errorf("server saw %d tools/list, want 2", n)
+		}
+	})
+
+	t.Run("tool call", func(t *testing.T) {
+		s := flakyServer()
+		c := newInitializedClient(t, s, WithRetry(policy))
+
+		// The server may have acted on a call it received
+		if _, err := c.CallTool(testContext(t), "echo", nil); !errors.Is(err, ErrInternal) {
+			t.Fatalf("CallTool = %v, want ErrInternal", err)
+		}
+		if n := len(s.requests("tools/call")); n != 1 {
+			t.Errorf("server saw %d tools/call, want 1", n)
+		}
+	})
+
+	t.Run("custom policy", func(t *testing.T) {
+		s := flakyServer()
+		custom := policy
+		custom.Retryable = func(method string, err error) bool {
+			return method == "tools/call" && errors.Is(err, ErrInternal)
+		}
+		c := newInitializedClient(t, s, WithRetry(custom))
+
+		if _, err := c.CallTool(testContext(t), "echo", nil); err != nil {
+			t.Fatalf("CallTool: %v", err)
+		}
+		if n := len(s.requests("tools/call")); n != 3 {
+			t.Errorf("server saw %d tools/call, want 3", n)
+		}
+	})
+
+	t.Run("zero backoff", func(t *testing.T) {
+		s := flakyServer()
+		zero := RetryPolicy{
+			MaxRetries: 3,
+			Retryable: func(method string, err error) bool {
+				return errors.Is(err, ErrInternal)
+			},
+		}
+		c := newInitializedClient(t, s, WithRetry(zero))
+
+		start := time.Now()
+		if _, err := c.CallTool(testContext(t), "echo", nil); err != nil {
+			t.Fatalf("CallTool: %v", err)
+		}
+		// Two retries with the default backoff wait at least 50ms then 100ms
+		if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+			t.Errorf("retried within %v, want the default backoff", elapsed)
+		}
+		if n := len(s.requests("tools/call")); n != 3 {
+			t.Errorf("server saw %d tools/call, want 3", n)
+		}
+	})
+}
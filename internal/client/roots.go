@@ -0,0 +1,21 @@
+package client
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// validateRoots checks that every root is a file:// URI, the only scheme
+// the protocol allows for now
+func validateRoots(roots []Root) error {
+	for _, root := range roots {
+		u, err := url.Parse(root.Uri)
+		if err != nil {
+			return fmt.Errorf("invalid root %q: %w", root.Uri, err)
+		}
+		if u.Scheme != "file" {
+			return fmt.Errorf("invalid root %q: must be a file:// URI", root.Uri)
+		}
+	}
+	return nil
+}
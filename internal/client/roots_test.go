@@ -0,0 +1,52 @@
+package client
+
+import (
+	"testing"
+)
+
+func TestRootsList(t *testing.T) {
+	s := newFakeServer()
+	c := newInitializedClient(t, s, WithRootsCapability(true))
+	ctx := testContext(t)
+
+	name := "project"
+	if err := c.SetRoots([]Root{{Uri: "file:///home/user/project", Name: &name}}); err != nil {
+		t.Fatalf("SetRoots: %v", err)
+	}
+	s.waitRequest(t, "notifications/roots/list_changed", 1)
+
+	var result ListRootsResult
+	if err := s.callClient(ctx, "roots/list", nil, &result); err != nil {
+		t.Fatalf("roots/list: %v", err)
+	}
+	if len(result.Roots) != 1 || result.Roots[0].Uri != "file:///home/user/project" ||
+		result.Roots[0].Name == nil || *result.Roots[0].Name != name {
+		t.Fatalf("roots/list = %+v", result.Roots)
+	}
+
+	if err := c.SetRoots([]Root{{Uri: "file:///tmp"}, {Uri: "file:///var/log"}}); err != nil {
+		t.Fatalf("SetRoots: %v", err)
+	}
+	s.waitRequest(t, "notifications/roots/list_changed", 2)
+	if err := s.callClient(ctx, "roots/list", nil, &result); err != nil {
+		t.Fatalf("roots/list: %v", err)
+	}
+	if len(result.Roots) != 2 || result.Roots[1].Uri != "file:///var/log" {
+		t.Fatalf("roots/list after update = %+v", result.Roots)
+	}
+}
+
+func TestSetRootsRejectsNonFileURIs(t *testing.T) {
+	s := newFakeServer()
+	c := newInitializedClient(t, s, WithRootsCapability(true))
+
+	if err := c.SetRoots([]Root{{Uri: "https://example.com/repo"}}); err == nil {
+		t.Fatal("SetRoots accepted an https root")
+	}
+	if len(c.handler.listRoots()) != 0 {
+		t.Error("invalid roots were stored")
+	}
+	if n := len(s.requests("notifications/roots/list_changed")); n != 0 {
+		t.Errorf("sent %d list changed notifications for invalid roots", n)
+	}
+}
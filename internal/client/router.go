@@ -0,0 +1,769 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RouterOption configures a Router
+type RouterOption func(*Router)
+
+// RouterWithPrefix names the tools and prompts of every server after the
+// server key and separator, such as "github__create_issue" with "__". Names
+// then never collide, and calls are routed by their prefix.
+func RouterWithPrefix(separator string) RouterOption {
+	return func(r *Router) {
+		r.separator = separator
+	}
+}
+
+// RouterWithScheme routes the resources whose URI has scheme, such as
+// "file", to the server registered under key
+func RouterWithScheme(scheme, key string) RouterOption {
+	return func(r *Router) {
+		r.schemes[strings.ToLower(scheme)] = key
+	}
+}
+
+// ConflictPolicy decides which server a Router routes a tool or prompt to
+// when several expose the same name and the names are not prefixed
+type ConflictPolicy int
+
+const (
+	// ConflictError fails with *ErrNameConflict, the default
+	ConflictError ConflictPolicy = iota
+	// ConflictFirst routes the name to the first server, in the order of
+	// the keys
+	ConflictFirst
+	// ConflictLast routes the name to the last server, in the order of the
+	// keys
+	ConflictLast
+)
+
+// RouterWithConflictPolicy sets what happens when two servers expose the
+// same tool or prompt name. The names that lose are left out of the lists.
+func RouterWithConflictPolicy(policy ConflictPolicy) RouterOption {
+	return func(r *Router) {
+		r.conflicts = policy
+	}
+}
+
+// ErrNameConflict is returned when two servers of a Router expose a tool or
+// a prompt with the same name and the names are not prefixed
+type ErrNameConflict struct {
+	// Kind is "tool" or "prompt"
+	Kind    string
+	Name    string
+	Servers []string
+}
+
+func (e *ErrNameConflict) Error() string {
+	return fmt.Sprintf("%s %q is exposed by servers %s",
+		e.Kind, e.Name, strings.Join(e.Servers, " and "))
+}
+
+// Router is a Client spreading the requests over several servers, each
+// reached by its own client registered under a key. Lists are merged from
+// all the servers. Tool calls and prompts are routed by name, resources by
+// URI scheme, and the other requests go to every server.
+//
+// Unless names are prefixed with RouterWithPrefix, Initialize and the lists
+// of tools and prompts fail with *ErrNameConflict when two servers expose
+// the same name, see RouterWithConflictPolicy.
+//
+// The router handles pagination itself: the lists return every page at
+// once and take no cursor.
+type Router struct {
+	clients   map[string]Client
+	keys      []string
+	separator string
+	schemes   map[string]string
+	conflicts ConflictPolicy
+
+	errOnce sync.Once
+	exit    *errNotifier
+
+	mu sync.Mutex
+	// tools and prompts map the names to the server keys, when the names
+	// are not prefixed
+	tools   map[string]string
+	prompts map[string]string
+}
+
+var _ Client = (*Router)(nil)
+
+// NewRouter creates a Router over clients, keyed by the name of their
+// server. The Router owns the clients and closes them on Close.
+func NewRouter(clients map[string]Client, opts ...RouterOption) (*Router, error) {
+	r := &Router{
+		clients: make(map[string]Client, len(clients)),
+		schemes: make(map[string]string),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if len(clients) == 0 {
+		return nil, errors.New("router needs at least one client")
+	}
+	for key, c := range clients {
+		if key == "" {
+			return nil, errors.New("empty server key")
+		}
+		if r.separator != "" && strings.Contains(key, r.separator) {
+			return nil, fmt.Errorf("server key %q contains the separator %q", key, r.separator)
+		}
+		r.clients[key] = c
+		r.keys = append(r.keys, key)
+	}
+	sort.Strings(r.keys)
+	for scheme, key := range r.schemes {
+		if _, ok := r.clients[key]; !ok {
+			return nil, fmt.Errorf("scheme %q routed to unknown server %q", scheme, key)
+		}
+	}
+	return r, nil
+}
+
+// each runs fn for every server concurrently and joins the errors, tagged
+// with the server key
+func (r *Router) each(fn func(key string, c Client) error) error {
+	errs := make([]error, len(r.keys))
+	var wg sync.WaitGroup
+	for i, key := range r.keys {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fn(key, r.clients[key]); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", key, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// listAll fetches every page of a list
+func listAll[T any](
+	ctx context.Context,
+	list func(ctx context.Context, cursor *string) ([]T, *string, error),
+) ([]T, error) {
+	var all []T
+	var cursor *string
+	for {
+		page, next, err := list(ctx, cursor)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if next == nil || *next == "" {
+			return all, nil
+		}
+		cursor = next
+	}
+}
+
+// listEach fetches a list from every server, in the order of the keys. The
+// servers that do not implement the list count as empty.
+func listEach[T any](
+	r *Router,
+	ctx context.Context,
+	cursor *string,
+	list func(c Client) func(ctx context.Context, cursor *string) ([]T, *string, error),
+) ([][]T, error) {
+	if cursor != nil && *cursor != "" {
+		return nil, errors.New("router lists are not paginated")
+	}
+	lists := make([][]T, len(r.keys))
+	index := make(map[string]int, len(r.keys))
+	for i, key := range r.keys {
+		index[key] = i
+	}
+	err := r.each(func(key string, c Client) error {
+		items, err := listAll(ctx, list(c))
+		if errors.Is(err, ErrMethodNotFound) {
+			// The server does not have such things
+			return nil
+		}
+		lists[index[key]] = items
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+// indexNames maps the names of every server to its key, resolving the
+// names exposed by several servers with policy
+func indexNames(
+	kind string,
+	keys []string,
+	names [][]string,
+	policy ConflictPolicy,
+) (map[string]string, error) {
+	index := make(map[string]string)
+	for i, key := range keys {
+		for _, name := range names[i] {
+			if other, ok := index[name]; ok && other != key {
+				switch policy {
+				case ConflictFirst:
+					continue
+				case ConflictLast:
+				default:
+					return nil, &ErrNameConflict{Kind: kind, Name: name, Servers: []string{other, key}}
+				}
+			}
+			index[name] = key
+		}
+	}
+	return index, nil
+}
+
+// names returns the names of the items of every server
+func names[T any](lists [][]T, name func(T) string) [][]string {
+	names := make([][]string, len(lists))
+	for i, list := range lists {
+		for _, item := range list {
+			names[i] = append(names[i], name(item))
+		}
+	}
+	return names
+}
+
+// route returns the client exposing the tool or prompt name, and the name
+// on its server
+func (r *Router) route(
+	ctx context.Context,
+	kind, name string,
+	index func() map[string]string,
+	refresh func(ctx context.Context) error,
+) (Client, string, error) {
+	if r.separator != "" {
+		key, rest, ok := strings.Cut(name, r.separator)
+		if c, known := r.clients[key]; ok && known {
+			return c, rest, nil
+		}
+		return nil, "", fmt.Errorf("unknown %s %q", kind, name)
+	}
+
+	key, ok := index()[name]
+	if !ok {
+		// Unknown, or listed since the index was built
+		if err := refresh(ctx); err != nil {
+			return nil, "", err
+		}
+		key, ok = index()[name]
+	}
+	if !ok {
+		return nil, "", fmt.Errorf("unknown %s %q", kind, name)
+	}
+	return r.clients[key], name, nil
+}
+
+func (r *Router) routeTool(ctx context.Context, name string) (Client, string, error) {
+	return r.route(ctx, "tool", name, func() map[string]string {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.tools
+	}, func(ctx context.Context) error {
+		_, _, err := r.ListTools(ctx, nil)
+		return err
+	})
+}
+
+func (r *Router) routePrompt(ctx context.Context, name string) (Client, string, error) {
+	return r.route(ctx, "prompt", name, func() map[string]string {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.prompts
+	}, func(ctx context.Context) error {
+		_, _, err := r.ListPrompts(ctx, nil)
+		return err
+	})
+}
+
+// routeURI returns the client serving the resource at uri
+func (r *Router) routeURI(uri string) (Client, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid resource URI %q: %w", uri, err)
+	}
+	key, ok := r.schemes[strings.ToLower(u.Scheme)]
+	if !ok {
+		return nil, fmt.Errorf("no server for resource %q", uri)
+	}
+	return r.clients[key], nil
+}
+
+// prefixed returns name as exposed by the router for the server key
+func (r *Router) prefixed(key, name string) string {
+	if r.separator == "" {
+		return name
+	}
+	return key + r.separator + name
+}
+
+// Initialize initializes every server and returns their merged capabilities.
+// The protocol version is the oldest one spoken by the servers. The tools
+// and prompts are then indexed, unless names are prefixed.
+func (r *Router) Initialize(ctx context.Context) (*ServerInfo, error) {
+	infos := make(map[string]*ServerInfo, len(r.keys))
+	var mu sync.Mutex
+	err := r.each(func(key string, c Client) error {
+		info, err := c.Initialize(ctx)
+		mu.Lock()
+		infos[key] = info
+		mu.Unlock()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	merged := &ServerInfo{ServerInfo: Implementation{Name: "mcpkit-router"}}
+	var instructions []string
+	for _, key := range r.keys {
+		info := infos[key]
+		merged.Capabilities = mergeCapabilities(merged.Capabilities, info.Capabilities)
+		if merged.ProtocolVersion == "" || info.ProtocolVersion < merged.ProtocolVersion {
+			merged.ProtocolVersion = info.ProtocolVersion
+		}
+		if info.Instructions != nil && *info.Instructions != "" {
+			instructions = append(instructions, key+": "+*info.Instructions)
+		}
+	}
+	if len(instructions) > 0 {
+		text := strings.Join(instructions, "\n\n")
+		merged.Instructions = &text
+	}
+
+	if r.separator == "" {
+		if merged.SupportsTools() {
+			if _, _, err := r.ListTools(ctx, nil); err != nil {
+				return nil, err
+			}
+		}
+		if merged.SupportsPrompts() {
+			if _, _, err := r.ListPrompts(ctx, nil); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return merged, nil
+}
+
+// ReinitializeCapabilities refreshes the capabilities of every server
+func (r *Router) ReinitializeCapabilities(ctx context.Context) error {
+	return r.each(func(_ string, c Client) error {
+		return c.ReinitializeCapabilities(ctx)
+	})
+}
+
+// Ping pings every server
+func (r *Router) Ping(ctx context.Context) error {
+	return r.each(func(_ string, c Client) error {
+		return c.Ping(ctx)
+	})
+}
+
+// ListTools lists the tools of every server
+func (r *Router) ListTools(ctx context.Context, cursor *string) ([]Tool, *string, error) {
+	lists, err := listEach(r, ctx, cursor, func(c Client) func(context.Context, *string) ([]Tool, *string, error) {
+		return c.ListTools
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var index map[string]string
+	if r.separator == "" {
+		toolNames := names(lists, func(tool Tool) string { return tool.Name })
+		index, err = indexNames("tool", r.keys, toolNames, r.conflicts)
+		if err != nil {
+			return nil, nil, err
+		}
+		r.mu.Lock()
+		r.tools = index
+		r.mu.Unlock()
+	}
+
+	var tools []Tool
+	for i, list := range lists {
+		for _, tool := range list {
+			if index != nil && index[tool.Name] != r.keys[i] {
+				// Shadowed by the tool of another server
+				continue
+			}
+			tool.Name = r.prefixed(r.keys[i], tool.Name)
+			tools = append(tools, tool)
+		}
+	}
+	return tools, nil, nil
+}
+
+// ListResources lists the resources of every server
+func (r *Router) ListResources(ctx context.Context, cursor *string) ([]Resource, *string, error) {
+	lists, err := listEach(r, ctx, cursor, func(c Client) func(context.Context, *string) ([]Resource, *string, error) {
+		return c.ListResources
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	var resources []Resource
+	for _, list := range lists {
+		resources = append(resources, list...)
+	}
+	return resources, nil, nil
+}
+
+// ListResourceTemplates lists the resource templates of every server
+func (r *Router) ListResourceTemplates(
+	ctx context.Context,
+	cursor *string,
+) ([]ResourceTemplate, *string, error) {
+	lists, err := listEach(r, ctx, cursor, func(c Client) func(context.Context, *string) ([]ResourceTemplate, *string, error) {
+		return c.ListResourceTemplates
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	var templates []ResourceTemplate
+	for _, list := range lists {
+		templates = append(templates, list...)
+	}
+	return templates, nil, nil
+}
+
+// ReadResource reads the resource from the server routed by its scheme
+func (r *Router) ReadResource(ctx context.Context, uri string) ([]ResourceContent, error) {
+	c, err := r.routeURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	return c.ReadResource(ctx, uri)
+}
+
+// ReadResourceRaw reads the resource from the server routed by its scheme
+func (r *Router) ReadResourceRaw(ctx context.Context, uri string) (*[]interface{}, error) {
+	c, err := r.routeURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	return c.ReadResourceRaw(ctx, uri)
+}
+
+// Subscribe subscribes to the resource on the server routed by its scheme
+func (r *Router) Subscribe(ctx context.Context, uri string, fn func(uri string)) error {
+	c, err := r.routeURI(uri)
+	if err != nil {
+		return err
+	}
+	return c.Subscribe(ctx, uri, fn)
+}
+
+// Unsubscribe cancels a subscription made with Subscribe
+func (r *Router) Unsubscribe(ctx context.Context, uri string) error {
+	c, err := r.routeURI(uri)
+	if err != nil {
+		return err
+	}
+	return c.Unsubscribe(ctx, uri)
+}
+
+// ListPrompts lists the prompts of every server
+func (r *Router) ListPrompts(ctx context.Context, cursor *string) ([]Prompt, *string, error) {
+	lists, err := listEach(r, ctx, cursor, func(c Client) func(context.Context, *string) ([]Prompt, *string, error) {
+		return c.ListPrompts
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var index map[string]string
+	if r.separator == "" {
+		promptNames := names(lists, func(prompt Prompt) string { return prompt.Name })
+		index, err = indexNames("prompt", r.keys, promptNames, r.conflicts)
+		if err != nil {
+			return nil, nil, err
+		}
+		r.mu.Lock()
+		r.prompts = index
+		r.mu.Unlock()
+	}
+
+	var prompts []Prompt
+	for i, list := range lists {
+		for _, prompt := range list {
+			if index != nil && index[prompt.Name] != r.keys[i] {
+				// Shadowed by the prompt of another server
+				continue
+			}
+			prompt.Name = r.prefixed(r.keys[i], prompt.Name)
+			prompts = append(prompts, prompt)
+		}
+	}
+	return prompts, nil, nil
+}
+
+// GetPrompt gets the prompt from the server exposing it
+func (r *Router) GetPrompt(
+	ctx context.Context,
+	name string,
+	args map[string]string,
+) (*GetPromptResult, error) {
+	c, name, err := r.routePrompt(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return c.GetPrompt(ctx, name, args)
+}
+
+// Complete asks the server exposing the prompt or the resource for the
+// completion
+func (r *Router) Complete(
+	ctx context.Context,
+	ref CompletionRef,
+	argName, argValue string,
+) (*CompletionResult, error) {
+	if ref.Type == "ref/resource" {
+		c, err := r.routeURI(ref.Uri)
+		if err != nil {
+			return nil, err
+		}
+		return c.Complete(ctx, ref, argName, argValue)
+	}
+	c, name, err := r.routePrompt(ctx, ref.Name)
+	if err != nil {
+		return nil, err
+	}
+	ref.Name = name
+	return c.Complete(ctx, ref, argName, argValue)
+}
+
+// SetLevel sets the log level of every server
+func (r *Router) SetLevel(ctx context.Context, level LoggingLevel) error {
+	return r.each(func(_ string, c Client) error {
+		return c.SetLevel(ctx, level)
+	})
+}
+
+// OnLogMessage registers fn for the log messages of every server
+func (r *Router) OnLogMessage(fn func(LoggingMessageNotification)) func() {
+	removes := make([]func(), 0, len(r.keys))
+	for _, key := range r.keys {
+		removes = append(removes, r.clients[key].OnLogMessage(fn))
+	}
+	return func() {
+		for _, remove := range removes {
+			remove()
+		}
+	}
+}
+
+// OnNotification registers fn for the notifications of every server
+func (r *Router) OnNotification(method string, fn NotificationHandler) func() {
+	removes := make([]func(), 0, len(r.keys))
+	for _, key := range r.keys {
+		removes = append(removes, r.clients[key].OnNotification(method, fn))
+	}
+	return func() {
+		for _, remove := range removes {
+			remove()
+		}
+	}
+}
+
+// SetSamplingHandler sets the sampling handler of every client
+func (r *Router) SetSamplingHandler(fn SamplingHandler) {
+	for _, key := range r.keys {
+		r.clients[key].SetSamplingHandler(fn)
+	}
+}
+
+// SetRoots sets the roots of every client
+func (r *Router) SetRoots(roots []Root) error {
+	return r.each(func(_ string, c Client) error {
+		return c.SetRoots(roots)
+	})
+}
+
+// SetRootsListHandler sets the roots handler of every client
+func (r *Router) SetRootsListHandler(fn func(ctx context.Context) ([]Root, error)) {
+	for _, key := range r.keys {
+		r.clients[key].SetRootsListHandler(fn)
+	}
+}
+
+// CallTool calls the tool on the server exposing it
+func (r *Router) CallTool(
+	ctx context.Context,
+	name string,
+	args interface{},
+	opts ...CallOption,
+) (*CallToolResult, error) {
+	c, name, err := r.routeTool(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return c.CallTool(ctx, name, args, opts...)
+}
+
+// Tool returns the tool called name from the tools cached for the server
+// exposing it. Without prefixes, the tools must have been listed by the
+// router.
+func (r *Router) Tool(name string) (*Tool, bool) {
+	key := ""
+	if r.separator != "" {
+		var ok bool
+		key, name, ok = strings.Cut(name, r.separator)
+		if !ok {
+			return nil, false
+		}
+	} else {
+		r.mu.Lock()
+		key = r.tools[name]
+		r.mu.Unlock()
+	}
+	c, ok := r.clients[key]
+	if !ok {
+		return nil, false
+	}
+	tool, ok := c.Tool(name)
+	if !ok {
+		return nil, false
+	}
+	renamed := *tool
+	renamed.Name = r.prefixed(key, tool.Name)
+	return &renamed, true
+}
+
+// CallToolValidated validates the arguments and calls the tool on the server
+// exposing it
+func (r *Router) CallToolValidated(
+	ctx context.Context,
+	name string,
+	args interface{},
+	opts ...CallOption,
+) (*CallToolResult, error) {
+	c, name, err := r.routeTool(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return c.CallToolValidated(ctx, name, args, opts...)
+}
+
+// StderrTail returns the last stderr lines of every server, each prefixed
+// with the server key
+func (r *Router) StderrTail() []string {
+	var lines []string
+	for _, key := range r.keys {
+		for _, line := range r.clients[key].StderrTail() {
+			lines = append(lines, key+": "+line)
+		}
+	}
+	return lines
+}
+
+// Supports reports whether one of the servers advertised capability
+func (r *Router) Supports(capability string) bool {
+	for _, key := range r.keys {
+		if r.clients[key].Supports(capability) {
+			return true
+		}
+	}
+	return false
+}
+
+// Healthy reports whether every server is healthy
+func (r *Router) Healthy() bool {
+	for _, key := range r.keys {
+		if !r.clients[key].Healthy() {
+			return false
+		}
+	}
+	return true
+}
+
+// LastPong returns the oldest of the last keepalive pongs of the servers,
+// the zero time if one never answered
+func (r *Router) LastPong() time.Time {
+	var oldest time.Time
+	for i, key := range r.keys {
+		pong := r.clients[key].LastPong()
+		if pong.IsZero() {
+			return time.Time{}
+		}
+		if i == 0 || pong.Before(oldest) {
+			oldest = pong
+		}
+	}
+	return oldest
+}
+
+// Stats returns the sum of the traffic of the servers, with the slowest of
+// their last pings
+func (r *Router) Stats() ConnectionStats {
+	var total ConnectionStats
+	for _, key := range r.keys {
+		stats := r.clients[key].Stats()
+		total.BytesSent += stats.BytesSent
+		total.BytesReceived += stats.BytesReceived
+		total.RequestsInFlight += stats.RequestsInFlight
+		total.TotalCalls += stats.TotalCalls
+		total.TotalErrors += stats.TotalErrors
+		total.LastPingLatency = max(total.LastPingLatency, stats.LastPingLatency)
+	}
+	return total
+}
+
+// Err returns a channel receiving the error of the first server that
+// fails, tagged with its key. It is closed once every client is closed.
+func (r *Router) Err() <-chan error {
+	r.errOnce.Do(func() {
+		r.exit = newErrNotifier()
+		errs := make(chan error, len(r.keys))
+		for _, key := range r.keys {
+			go func() {
+				if err := <-r.clients[key].Err(); err != nil {
+					errs <- fmt.Errorf("%s: %w", key, err)
+					return
+				}
+				errs <- nil
+			}()
+		}
+		go func() {
+			for range r.keys {
+				if err := <-errs; err != nil {
+					r.exit.fire(err)
+				}
+			}
+			r.exit.fire(nil)
+		}()
+	})
+	return r.exit.ch
+}
+
+// CallBatch is not supported, a batch cannot be routed to a single server
+func (r *Router) CallBatch(ctx context.Context, reqs []BatchRequest) ([]BatchResponse, error) {
+	return nil, errors.New("batches are not supported by the router")
+}
+
+// Close closes every client
+func (r *Router) Close() error {
+	return r.each(func(_ string, c Client) error {
+		return c.Close()
+	})
+}
+
+// DrainAndClose drains and closes every client
+func (r *Router) DrainAndClose(ctx context.Context) error {
+	return r.each(func(_ string, c Client) error {
+		return c.DrainAndClose(ctx)
+	})
+}
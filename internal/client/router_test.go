@@ -0,0 +1,135 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sort"
+	"testing"
+)
+
+// toolServer is a fake server exposing the tools get_time and only_<name>,
+// whose calls answer with name
+func toolServer(name string) *fakeServer {
+	s := newFakeServer()
+	s.caps = map[string]interface{}{"tools": map[string]interface{}{}}
+	s.handle("tools/list", func(context.Context, json.RawMessage) (interface{}, error) {
+		return ListToolsResult{Tools: []Tool{{Name: "get_time"}, {Name: "only_" + name}}}, nil
+	})
+	s.handle("tools/call", func(context.Context, json.RawMessage) (interface{}, error) {
+		return textResult(name), nil
+	})
+	return s
+}
+
+// newTestRouter routes to two fake servers, "a" and "b", exposing the same
+// get_time tool
+func newTestRouter(t *testing.T, opts ...RouterOption) *Router {
+	t.Helper()
+	r, err := NewRouter(map[string]Client{
+		"a": newTestClient(t, toolServer("a")),
+		"b": newTestClient(t, toolServer("b")),
+	}, opts...)
+	if err != nil {
+		t.Fatalf("failed to create the router: %v", err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func TestRouterSameToolNames(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []RouterOption
+		tools []string
+		// calls maps the tool called to the server expected to answer
+		calls map[string]string
+	}{
+		{
+			name:  "dot prefix",
+			opts:  []RouterOption{RouterWithPrefix(".")},
+			tools: []string{"a.get_time", "a.only_a", "b.get_time", "b.only_b"},
+			calls: map[string]string{"a.get_time": "a", "b.get_time": "b", "b.only_b": "b"},
+		},
+		{
+			name:  "prefix",
+			opts:  []RouterOption{RouterWithPrefix("__")},
+			tools: []string{"a__get_time", "a__only_a", "b__get_time", "b__only_b"},
+			calls: map[string]string{"a__get_time": "a", "b__get_time": "b"},
+		},
+		{
+			name:  "first",
+			opts:  []RouterOption{RouterWithConflictPolicy(ConflictFirst)},
+			tools: []string{"get_time", "only_a", "only_b"},
+			calls: map[string]string{"get_time": "a", "only_b": "b"},
+		},
+		{
+			name:  "last",
+			opts:  []RouterOption{RouterWithConflictPolicy(ConflictLast)},
+			tools: []string{"get_time", "only_a", "only_b"},
+			calls: map[string]string{"get_time": "b", "only_a": "a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRouter(t, tt.opts...)
+			ctx := testContext(t)
+			if _, err := r.Initialize(ctx); err != nil {
+				t.Fatalf("Initialize: %v", err)
+			}
+
+			tools, _, err := r.ListTools(ctx, nil)
+			if err != nil {
+				t.Fatalf("ListTools: %v", err)
+			}
+			var names []string
+			for _, tool := range tools {
+				names = append(names, tool.Name)
+			}
+			sort.Strings(names)
+			if !slices.Equal(names, tt.tools) {
+				t.Errorf("tools = %v, want %v", names, tt.tools)
+			}
+
+			for tool, server := range tt.calls {
+				result, err := r.CallTool(ctx, tool, nil)
+				if err != nil {
+					t.Fatalf("CallTool(%s): %v", tool, err)
+				}
+				texts, _ := result.TextContent()
+				if len(texts) != 1 || texts[0].Text != server {
+					t.Errorf("CallTool(%s) answered by %v, want %s", tool, texts, server)
+				}
+			}
+		})
+	}
+}
+
+func TestRouterNameConflict(t *testing.T) {
+	r := newTestRouter(t)
+
+	_, err := r.Initialize(testContext(t))
+	var conflict *ErrNameConflict
+	if !errors.As(err, &conflict) {
+		t.Fatalf("Initialize = %v, want *ErrNameConflict", err)
+	}
+	if conflict.Kind != "tool" || conflict.Name != "get_time" ||
+		!slices.Equal(conflict.Servers, []string{"a", "b"}) {
+		t.Errorf("conflict = %+v", conflict)
+	}
+}
+
+func TestRouterUnknownTool(t *testing.T) {
+	r := newTestRouter(t, RouterWithPrefix("."))
+	ctx := testContext(t)
+	if _, err := r.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	if _, err := r.CallTool(ctx, "c.get_time", nil); err == nil {
+		t.Error("CallTool routed a tool of an unknown server")
+	}
+	if _, err := r.CallTool(ctx, "get_time", nil); err == nil {
+		t.Error("CallTool routed a tool name without its server prefix")
+	}
+}
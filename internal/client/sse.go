@@ -0,0 +1,271 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/exp/jsonrpc2"
+)
+
+// NewSSE creates a client for a server using the HTTP+SSE transport at
+// baseURL. The client listens to the server messages on an SSE stream
+// opened with a GET on baseURL, and posts its own messages to the endpoint
+// the server announces in the first event of the stream. Use
+// WithHTTPClient and WithHTTPHeader to configure the requests, such as for
+// authentication. Connecting, up to the endpoint event, is bounded by
+// WithConnectTimeout. The client closes when the server ends the stream.
+func NewSSE(
+	ctx context.Context,
+	logger *slog.Logger,
+	baseURL string,
+	opts ...Option,
+) (Client, error) {
+	o := newOptions(opts)
+	return NewWithTransport(ctx, logger, &sseTransport{
+		url:     baseURL,
+		client:  o.httpClient(),
+		header:  o.httpHeader,
+		timeout: o.connectTimeout,
+	}, opts...)
+}
+
+// sseTransport connects to a server using the HTTP+SSE transport
+type sseTransport struct {
+	url     string
+	client  *http.Client
+	header  http.Header
+	timeout time.Duration
+}
+
+func (t *sseTransport) Dial(ctx context.Context) (io.ReadWriteCloser, error) {
+	base, err := url.Parse(t.url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %q: %w", t.url, err)
+	}
+
+	// The stream outlives ctx, it lasts until the client is closed
+	streamCtx, cancel := context.WithCancel(context.Background())
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+	var timedOut atomic.Bool
+	if t.timeout > 0 {
+		timer := time.AfterFunc(t.timeout, func() {
+			timedOut.Store(true)
+			cancel()
+		})
+		defer timer.Stop()
+	}
+
+	stream, err := t.open(streamCtx, base)
+	if err != nil {
+		cancel()
+		if timedOut.Load() {
+			err = fmt.Errorf("no endpoint after %s", t.timeout)
+		}
+		return nil, fmt.Errorf("failed to connect to %s: %w", t.url, err)
+	}
+	stream.cancel = cancel
+	return stream, nil
+}
+
+// open opens the SSE stream and waits for the endpoint event
+func (t *sseTransport) open(ctx context.Context, base *url.URL) (*sseStream, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	setHeaders(req, t.header)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	stream := &sseStream{
+		ctx:    ctx,
+		client: t.client,
+		header: t.header,
+		body:   resp.Body,
+		events: bufio.NewReader(resp.Body),
+	}
+	for {
+		event, data, err := stream.nextEvent()
+		if err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("waiting for the endpoint: %w", err)
+		}
+		if event != "endpoint" {
+			continue
+		}
+		endpoint, err := base.Parse(strings.TrimSpace(data))
+		if err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("invalid endpoint %q: %w", data, err)
+		}
+		stream.endpoint = endpoint.String()
+		return stream, nil
+	}
+}
+
+func (*sseTransport) framer() jsonrpc2.Framer {
+	return NewLineRawFramer()
+}
+
+// messageLine returns the message in data on a single line, which is how
+// the newline framer reads it
+func messageLine(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return nil, fmt.Errorf("invalid message: %w", err)
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// setHeaders adds header to the headers of req
+func setHeaders(req *http.Request, header http.Header) {
+	for key, values := range header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+}
+
+// sseStream reads the server messages from the SSE stream, one per line,
+// and posts every write to the endpoint. It is used with the newline
+// framer, which writes each message at once.
+type sseStream struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	client   *http.Client
+	header   http.Header
+	endpoint string
+
+	body   io.ReadCloser
+	events *bufio.Reader
+	// pending holds what is left of the message being read
+	pending []byte
+
+	closeOnce sync.Once
+}
+
+// nextEvent reads the next event of the stream
+func (s *sseStream) nextEvent() (event, data string, err error) {
+	event, data, err = readSSEEvent(s.events)
+	if err == io.EOF {
+		// The server is not supposed to end the stream
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("SSE stream ended: %w", err)
+	}
+	return event, data, nil
+}
+
+// readSSEEvent reads the next event with data from r. The event type
+// defaults to "message". It returns io.EOF once r ends.
+func readSSEEvent(r *bufio.Reader) (event, data string, err error) {
+	var lines []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", "", err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		if line == "" {
+			// A blank line dispatches the event, if it has data
+			if lines == nil {
+				event = ""
+				continue
+			}
+			if event == "" {
+				event = "message"
+			}
+			return event, strings.Join(lines, "\n"), nil
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			lines = append(lines, value)
+		}
+		// Comments, starting with a colon, and the other fields are ignored
+	}
+}
+
+func (s *sseStream) Read(p []byte) (int, error) {
+	for len(s.pending) == 0 {
+		event, data, err := s.nextEvent()
+		if err != nil {
+			if s.ctx.Err() != nil {
+				// Close ended the stream
+				return 0, io.EOF
+			}
+			return 0, err
+		}
+		if event != "message" {
+			continue
+		}
+
+		line, err := messageLine([]byte(data))
+		if err != nil {
+			return 0, err
+		}
+		s.pending = line
+	}
+
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+func (s *sseStream) Write(p []byte) (int, error) {
+	req, err := http.NewRequestWithContext(
+		s.ctx, http.MethodPost, s.endpoint, bytes.NewReader(bytes.TrimSpace(p)),
+	)
+	if err != nil {
+		return 0, err
+	}
+	setHeaders(req, s.header)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to post message: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return 0, fmt.Errorf("failed to post message: unexpected status %s", resp.Status)
+	}
+	return len(p), nil
+}
+
+func (s *sseStream) Close() error {
+	s.closeOnce.Do(func() {
+		s.cancel()
+		s.body.Close()
+	})
+	return nil
+}
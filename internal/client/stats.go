@@ -0,0 +1,101 @@
+package client
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/exp/jsonrpc2"
+)
+
+// ConnectionStats describes the traffic of a client with its server
+type ConnectionStats struct {
+	BytesSent     int64
+	BytesReceived int64
+	// RequestsInFlight counts the requests waiting for a response
+	RequestsInFlight int
+	// LastPingLatency is the round trip of the last answered ping, sent
+	// with Ping or by the keepalive
+	LastPingLatency time.Duration
+	TotalCalls      int64
+	TotalErrors     int64
+}
+
+// connStats holds the counters behind ConnectionStats
+type connStats struct {
+	bytesSent     atomic.Int64
+	bytesReceived atomic.Int64
+	inFlight      atomic.Int64
+	lastPing      atomic.Int64
+	calls         atomic.Int64
+	errors        atomic.Int64
+}
+
+func (s *connStats) started() {
+	s.calls.Add(1)
+	s.inFlight.Add(1)
+}
+
+func (s *connStats) finished(method string, d time.Duration, err error) {
+	s.inFlight.Add(-1)
+	if err != nil {
+		s.errors.Add(1)
+	} else if method == "ping" {
+		s.lastPing.Store(int64(d))
+	}
+}
+
+// Stats returns the traffic of the client since it connected
+func (c *client) Stats() ConnectionStats {
+	return ConnectionStats{
+		BytesSent:        c.stats.bytesSent.Load(),
+		BytesReceived:    c.stats.bytesReceived.Load(),
+		RequestsInFlight: int(c.stats.inFlight.Load()),
+		LastPingLatency:  time.Duration(c.stats.lastPing.Load()),
+		TotalCalls:       c.stats.calls.Load(),
+		TotalErrors:      c.stats.errors.Load(),
+	}
+}
+
+// countingFramer counts the bytes read and written by the framer it wraps
+type countingFramer struct {
+	base  jsonrpc2.Framer
+	stats *connStats
+}
+
+func (f countingFramer) Reader(r io.Reader) jsonrpc2.Reader {
+	return &countingReader{base: f.base.Reader(r), stats: f.stats}
+}
+
+func (f countingFramer) Writer(w io.Writer) jsonrpc2.Writer {
+	return &countingWriter{base: f.base.Writer(w), stats: f.stats}
+}
+
+type countingReader struct {
+	base  jsonrpc2.Reader
+	stats *connStats
+}
+
+func (r *countingReader) Read(ctx context.Context) (jsonrpc2.Message, int64, error) {
+	msg, n, err := r.base.Read(ctx)
+	r.stats.bytesReceived.Add(n)
+	return msg, n, err
+}
+
+type countingWriter struct {
+	base  jsonrpc2.Writer
+	stats *connStats
+}
+
+func (w *countingWriter) Write(ctx context.Context, msg jsonrpc2.Message) (int64, error) {
+	n, err := w.base.Write(ctx, msg)
+	w.stats.bytesSent.Add(n)
+	return n, err
+}
+
+func (w *countingWriter) writeBatch(ctx context.Context, msgs []jsonrpc2.Message) (int64, error) {
+	n, err := writeBatch(ctx, w.base, msgs)
+	w.stats.bytesSent.Add(n)
+	return n, err
+}
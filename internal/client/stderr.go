@@ -0,0 +1,122 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The stderr tail keeps at most stderrTailLines lines and stderrTailBytes
+// bytes of the server stderr
+const (
+	stderrTailLines = 200
+	stderrTailBytes = 64 * 1024
+)
+
+// stderrTail is a ring buffer holding the last lines the server wrote to
+// stderr
+type stderrTail struct {
+	mu    sync.Mutex
+	lines [stderrTailLines]string
+	// start is the index of the oldest line, n the number of lines
+	start, n int
+	bytes    int
+}
+
+func newStderrTail() *stderrTail {
+	return &stderrTail{}
+}
+
+// add appends line, dropping the oldest lines past the limits
+func (t *stderrTail) add(line string) {
+	if len(line) > stderrTailBytes {
+		line = line[len(line)-stderrTailBytes:]
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for t.n > 0 && (t.n == stderrTailLines || t.bytes+len(line) > stderrTailBytes) {
+		t.bytes -= len(t.lines[t.start])
+		t.lines[t.start] = ""
+		t.start = (t.start + 1) % stderrTailLines
+		t.n--
+	}
+	t.lines[(t.start+t.n)%stderrTailLines] = line
+	t.n++
+	t.bytes += len(line)
+}
+
+// last returns up to n of the last lines, oldest first. A negative n
+// returns all of them.
+func (t *stderrTail) last(n int) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if n < 0 || n > t.n {
+		n = t.n
+	}
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = t.lines[(t.start+t.n-n+i)%stderrTailLines]
+	}
+	return lines
+}
+
+// StderrTail returns the last lines the server wrote to stderr, oldest
+// first, up to 200 lines and 64KB
+func (c *client) StderrTail() []string {
+	return c.stderrTail.last(-1)
+}
+
+// logStructured re-emits a line written by a slog JSON handler with the
+// client logger, keeping its level, message and attributes. It reports
+// whether the line was such a record.
+func (c *client) logStructured(line string) bool {
+	if !strings.HasPrefix(line, "{") {
+		return false
+	}
+	dec := json.NewDecoder(strings.NewReader(line))
+	dec.UseNumber()
+	var record map[string]interface{}
+	if err := dec.Decode(&record); err != nil {
+		return false
+	}
+	msg, ok := record[slog.MessageKey].(string)
+	if !ok {
+		return false
+	}
+	text, ok := record[slog.LevelKey].(string)
+	var level slog.Level
+	if !ok || level.UnmarshalText([]byte(text)) != nil {
+		return false
+	}
+	delete(record, slog.MessageKey)
+	delete(record, slog.LevelKey)
+	// The time is the one of the client record
+	delete(record, slog.TimeKey)
+
+	c.logger.Log(context.Background(), level, msg, structuredAttrs(record)...)
+	return true
+}
+
+// structuredAttrs turns the fields of a JSON record into attributes, the
+// objects into groups
+func structuredAttrs(fields map[string]interface{}) []any {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]any, 0, len(keys))
+	for _, key := range keys {
+		if group, ok := fields[key].(map[string]interface{}); ok {
+			attrs = append(attrs, slog.Group(key, structuredAttrs(group)...))
+			continue
+		}
+		attrs = append(attrs, slog.Any(key, fields[key]))
+	}
+	return attrs
+}
@@ -0,0 +1,341 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/jsonrpc2"
+)
+
+const (
+	// sessionHeader carries the session ID of the Streamable HTTP transport
+	sessionHeader = "Mcp-Session-Id"
+
+	// streamRetryDelay is how long the client waits before opening the
+	// server stream again once it ended
+	streamRetryDelay = time.Second
+
+	// sessionDeleteTimeout bounds the request ending the session on Close
+	sessionDeleteTimeout = 5 * time.Second
+)
+
+// ErrSessionExpired is returned when a Streamable HTTP server no longer
+// knows the session of the client and answers with 404. The client closes,
+// and a new client has to be created to start a new session.
+type ErrSessionExpired struct {
+	SessionID string
+}
+
+func (e *ErrSessionExpired) Error() string {
+	return fmt.Sprintf("session %s expired", e.SessionID)
+}
+
+// NewStreamableHTTP creates a client for a server using the Streamable HTTP
+// transport at url. Every message is posted to url, the server answering
+// with JSON or with an SSE stream. The session ID the server returns on
+// initialize is sent with every later request, and a GET stream is kept
+// open for the messages the server sends on its own. Use WithHTTPClient
+// and WithHTTPHeader to configure the requests, such as for
+// authentication. Close ends the session.
+//
+// A message is posted before the next one is written, up to the response
+// headers, so a server answering calls with plain JSON handles them one at
+// a time.
+func NewStreamableHTTP(
+	ctx context.Context,
+	logger *slog.Logger,
+	url string,
+	opts ...Option,
+) (Client, error) {
+	o := newOptions(opts)
+	return NewWithTransport(ctx, logger, &httpTransport{
+		url:    url,
+		client: o.httpClient(),
+		header: o.httpHeader,
+	}, opts...)
+}
+
+// httpTransport connects to a server using the Streamable HTTP transport
+type httpTransport struct {
+	url    string
+	client *http.Client
+	header http.Header
+}
+
+func (t *httpTransport) Dial(ctx context.Context) (io.ReadWriteCloser, error) {
+	if _, err := url.Parse(t.url); err != nil {
+		return nil, fmt.Errorf("invalid URL %q: %w", t.url, err)
+	}
+
+	// Nothing is sent before the first message, the stream lasts until
+	// the client is closed
+	streamCtx, cancel := context.WithCancel(context.Background())
+	return &httpStream{
+		ctx:      streamCtx,
+		cancel:   cancel,
+		url:      t.url,
+		client:   t.client,
+		header:   t.header,
+		incoming: make(chan []byte),
+		failed:   make(chan struct{}),
+	}, nil
+}
+
+func (*httpTransport) framer() jsonrpc2.Framer {
+	return NewLineRawFramer()
+}
+
+// httpStream posts every write and reads the messages the server sends
+// in the responses and on the GET stream, one per line. It is used with
+// the newline framer, which writes each message at once.
+type httpStream struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	url    string
+	client *http.Client
+	header http.Header
+
+	mu        sync.Mutex
+	sessionID string
+
+	// incoming carries the messages received, as lines
+	incoming chan []byte
+	// pending holds what is left of the message being read
+	pending []byte
+
+	// failed is closed once the stream failed with err
+	failOnce sync.Once
+	failed   chan struct{}
+	err      error
+
+	streamOnce sync.Once
+	closeOnce  sync.Once
+}
+
+func (s *httpStream) session() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessionID
+}
+
+// fail makes the reads fail with err, which closes the client
+func (s *httpStream) fail(err error) {
+	s.failOnce.Do(func() {
+		s.err = err
+		close(s.failed)
+	})
+}
+
+// newRequest creates a request to the server, carrying the session ID
+func (s *httpStream) newRequest(method string, body io.Reader) (*http.Request, string, error) {
+	req, err := http.NewRequestWithContext(s.ctx, method, s.url, body)
+	if err != nil {
+		return nil, "", err
+	}
+	setHeaders(req, s.header)
+	sessionID := s.session()
+	if sessionID != "" {
+		req.Header.Set(sessionHeader, sessionID)
+	}
+	return req, sessionID, nil
+}
+
+func (s *httpStream) Read(p []byte) (int, error) {
+	for len(s.pending) == 0 {
+		select {
+		case line := <-s.incoming:
+			s.pending = line
+		case <-s.failed:
+			return 0, s.err
+		case <-s.ctx.Done():
+			// Close ended the stream
+			return 0, io.EOF
+		}
+	}
+
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+// Write posts the message in p. The response is read in the background
+// once its headers arrived.
+func (s *httpStream) Write(p []byte) (int, error) {
+	msg := bytes.TrimSpace(p)
+	req, sessionID, err := s.newRequest(http.MethodPost, bytes.NewReader(msg))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to post message: %w", err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound && sessionID != "":
+		resp.Body.Close()
+		err := &ErrSessionExpired{SessionID: sessionID}
+		s.fail(err)
+		return 0, err
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return 0, fmt.Errorf("failed to post message: unexpected status %s", resp.Status)
+	}
+
+	if id := resp.Header.Get(sessionHeader); id != "" && sessionID == "" {
+		s.mu.Lock()
+		s.sessionID = id
+		s.mu.Unlock()
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/event-stream":
+		go s.readResponse(resp.Body)
+	case "application/json":
+		go s.readJSON(resp.Body)
+	default:
+		// Accepted notifications and responses have no body
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	// The server stream is opened once the handshake is done
+	var head struct {
+		Method string `json:"method"`
+	}
+	if json.Unmarshal(msg, &head) == nil && head.Method == "notifications/initialized" {
+		s.streamOnce.Do(func() { go s.listen() })
+	}
+	return len(p), nil
+}
+
+// push hands a message received in data to Read
+func (s *httpStream) push(data []byte) bool {
+	line, err := messageLine(data)
+	if err != nil {
+		s.fail(err)
+		return false
+	}
+	select {
+	case s.incoming <- line:
+		return true
+	case <-s.failed:
+	case <-s.ctx.Done():
+	}
+	return false
+}
+
+func (s *httpStream) readJSON(body io.ReadCloser) {
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		if s.ctx.Err() == nil {
+			s.fail(fmt.Errorf("failed to read response: %w", err))
+		}
+		return
+	}
+	s.push(data)
+}
+
+// readResponse hands the messages of the SSE stream answering a post to
+// Read. The stream ending on an error fails the client, as the responses
+// it still had to carry are lost.
+func (s *httpStream) readResponse(body io.ReadCloser) {
+	if err := s.readEvents(body); err != nil && s.ctx.Err() == nil {
+		s.fail(fmt.Errorf("SSE stream ended: %w", err))
+	}
+}
+
+// readEvents hands the messages of an SSE stream to Read until it ends
+func (s *httpStream) readEvents(body io.ReadCloser) error {
+	defer body.Close()
+	events := bufio.NewReader(body)
+	for {
+		event, data, err := readSSEEvent(events)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if event == "message" && !s.push([]byte(data)) {
+			return nil
+		}
+	}
+}
+
+// listen keeps a GET stream open for the messages the server sends on its
+// own, unless the server does not offer one
+func (s *httpStream) listen() {
+	for {
+		req, sessionID, err := s.newRequest(http.MethodGet, nil)
+		if err != nil {
+			return
+		}
+		req.Header.Set("Accept", "text/event-stream")
+
+		resp, err := s.client.Do(req)
+		switch {
+		case err != nil:
+		case resp.StatusCode == http.StatusNotFound && sessionID != "":
+			resp.Body.Close()
+			s.fail(&ErrSessionExpired{SessionID: sessionID})
+			return
+		case resp.StatusCode != http.StatusOK:
+			// 405 means the server has no such stream
+			resp.Body.Close()
+			return
+		default:
+			// Dropped, the stream is opened again
+			_ = s.readEvents(resp.Body)
+		}
+
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-s.failed:
+			return
+		case <-time.After(streamRetryDelay):
+		}
+	}
+}
+
+// Close stops the streams and ends the session
+func (s *httpStream) Close() error {
+	s.closeOnce.Do(func() {
+		s.cancel()
+
+		sessionID := s.session()
+		if sessionID == "" {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), sessionDeleteTimeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.url, nil)
+		if err != nil {
+			return
+		}
+		setHeaders(req, s.header)
+		req.Header.Set(sessionHeader, sessionID)
+		// The server may not allow ending sessions, nothing to do about it
+		if resp, err := s.client.Do(req); err == nil {
+			resp.Body.Close()
+		}
+	})
+	return nil
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"time"
+)
+
+// NewTCP creates a client for a server listening on the TCP address addr,
+// speaking newline delimited JSON-RPC like a stdio server. Connecting is
+// bounded by WithConnectTimeout, and WithTLSConfig connects over TLS.
+// Close closes the connection.
+func NewTCP(
+	ctx context.Context,
+	logger *slog.Logger,
+	addr string,
+	opts ...Option,
+) (Client, error) {
+	o := newOptions(opts)
+	return NewWithTransport(ctx, logger, tcpTransport{
+		addr:      addr,
+		timeout:   o.connectTimeout,
+		tlsConfig: o.tlsConfig,
+	}, opts...)
+}
+
+// tcpTransport connects to a server listening on TCP, over TLS when
+// tlsConfig is set
+type tcpTransport struct {
+	addr      string
+	timeout   time.Duration
+	tlsConfig *tls.Config
+}
+
+func (t tcpTransport) Dial(ctx context.Context) (io.ReadWriteCloser, error) {
+	dialer := &net.Dialer{Timeout: t.timeout}
+	var conn net.Conn
+	var err error
+	if t.tlsConfig == nil {
+		conn, err = dialer.DialContext(ctx, "tcp", t.addr)
+	} else {
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: t.tlsConfig}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", t.addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", t.addr, err)
+	}
+	return conn, nil
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer ln.Close()
+
+	s := newFakeServer()
+	s.handle("tools/list", func(context.Context, json.RawMessage) (interface{}, error) {
+		return ListToolsResult{Tools: []Tool{{Name: "echo"}}}, nil
+	})
+	s.handle("tools/call", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var p CallToolRequestParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		text, _ := p.Arguments["text"].(string)
+		return textResult(text), nil
+	})
+	served := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			served <- err
+			return
+		}
+		sc, err := s.serve(context.Background(), conn)
+		if err != nil {
+			served <- err
+			return
+		}
+		served <- sc.Wait()
+	}()
+
+	ctx := testContext(t)
+	c, err := NewTCP(ctx, testLogger(), ln.Addr().String(), WithConnectTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("NewTCP: %v", err)
+	}
+	if _, err := c.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	tools, _, err := c.ListTools(ctx, nil)
+	if err != nil || len(tools) != 1 || tools[0].Name != "echo" {
+		t.Fatalf("ListTools = %v, %v", tools, err)
+	}
+	result, err := c.CallTool(ctx, "echo", map[string]interface{}{"text": "over tcp"})
+	if err != nil {
+		t.Fatalf("CallTool: %v", err)
+	}
+	texts, err := result.TextContent()
+	if err != nil || len(texts) != 1 || texts[0].Text != "over tcp" {
+		t.Errorf("CallTool = %+v, %v", result, err)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	// Closing the socket ends the connection of the server
+	select {
+	case <-served:
+	case <-ctx.Done():
+		t.Fatal("the server connection is still open after Close")
+	}
+}
+
+func TestTCPConnectRefused(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	c, err := NewTCP(testContext(t), testLogger(), addr, WithConnectTimeout(time.Second))
+	if err == nil {
+		c.Close()
+		t.Fatal("NewTCP succeeded with nothing listening")
+	}
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExpandTemplate turns the URI template of tpl into a concrete URI using
+// the simple string expansion of RFC 6570 (level 1). Each {var} is replaced
+// by the percent-encoded value of vars[var]; undefined variables expand to
+// an empty string. Templates using operators of the higher levels are
+// rejected.
+func ExpandTemplate(tpl ResourceTemplate, vars map[string]string) (string, error) {
+	src := tpl.UriTemplate
+
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(src, '{')
+		if start < 0 {
+			if strings.IndexByte(src, '}') >= 0 {
+				return "", fmt.Errorf("invalid template %q: unmatched '}'", tpl.UriTemplate)
+			}
+			b.WriteString(src)
+			return b.String(), nil
+		}
+
+		end := strings.IndexByte(src[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("invalid template %q: unclosed expression", tpl.UriTemplate)
+		}
+		end += start
+
+		literal := src[:start]
+		if strings.IndexByte(literal, '}') >= 0 {
+			return "", fmt.Errorf("invalid template %q: unmatched '}'", tpl.UriTemplate)
+		}
+		b.WriteString(literal)
+
+		name := src[start+1 : end]
+		if !isVarName(name) {
+			return "", fmt.Errorf(
+				"invalid template %q: unsupported expression {%s}",
+				tpl.UriTemplate, name,
+			)
+		}
+		b.WriteString(encodeUnreserved(vars[name]))
+
+		src = src[end+1:]
+	}
+}
+
+// isVarName reports whether name is a valid level 1 variable name
+func isVarName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		switch c := name[i]; {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '_':
+		case c == '.' && i > 0 && i < len(name)-1 && name[i-1] != '.':
+		case c == '%' && i+2 < len(name) && isHex(name[i+1]) && isHex(name[i+2]):
+			i += 2
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// encodeUnreserved percent-encodes every byte of s outside the unreserved
+// set of RFC 3986
+func encodeUnreserved(s string) string {
+	const hex = "0123456789ABCDEF"
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~':
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&0x0f])
+		}
+	}
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
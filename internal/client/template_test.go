@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"golang.org/x/exp/jsonrpc2"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	for _, tt := range []struct {
+		template string
+		vars     map[string]string
+		want     string
+		wantErr  bool
+	}{
+		{template: "file:///logs/{date}", vars: map[string]string{"date": "2024-01-15"}, want: "file:///logs/2024-01-15"},
+		{template: "file:///logs/{date}.log", vars: map[string]string{"date": "2024-01-15"}, want: "file:///logs/2024-01-15.log"},
+		{template: "file:///logs/{date}", vars: map[string]string{"date": "a b/c"}, want: "file:///logs/a%20b%2Fc"},
+		{template: "file:///logs/{date}", want: "file:///logs/"},
+		{template: "db://{table}/{id}", vars: map[string]string{"table": "users", "id": "42"}, want: "db://users/42"},
+		{template: "file:///logs/latest", want: "file:///logs/latest"},
+		{template: "file:///logs/{date", wantErr: true},
+		{template: "file:///logs/date}", wantErr: true},
+		{template: "file:///logs/{+path}", wantErr: true},
+	} {
+		t.Run(tt.template, func(t *testing.T) {
+			got, err := ExpandTemplate(ResourceTemplate{UriTemplate: tt.template}, tt.vars)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ExpandTemplate = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ExpandTemplate = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadExpandedTemplate(t *testing.T) {
+	s := newFakeServer()
+	s.handle("resources/templates/list", func(context.Context, json.RawMessage) (interface{}, error) {
+		return ListResourceTemplatesResult{ResourceTemplates: []ResourceTemplate{
+			{Name: "logs", UriTemplate: "file:///logs/{date}"},
+		}}, nil
+	})
+	s.handle("resources/read", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var p ReadResourceRequestParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, jsonrpc2.ErrInvalidParams
+		}
+		if p.Uri != "file:///logs/2024-01-15" {
+			return nil, jsonrpc2.NewError(CodeResourceNotFound, "Resource not found")
+		}
+		return map[string]interface{}{"contents": []interface{}{
+			map[string]interface{}{"uri": p.Uri, "text": "started"},
+		}}, nil
+	})
+	c := newInitializedClient(t, s)
+	ctx := testContext(t)
+
+	templates, _, err := c.ListResourceTemplates(ctx, nil)
+	if err != nil || len(templates) != 1 {
+		t.Fatalf("ListResourceTemplates = %v, %v", templates, err)
+	}
+	uri, err := ExpandTemplate(templates[0], map[string]string{"date": "2024-01-15"})
+	if err != nil {
+		t.Fatalf("ExpandTemplate: %v", err)
+	}
+	contents, err := c.ReadResource(ctx, uri)
+	if err != nil {
+		t.Fatalf("ReadResource(%s): %v", uri, err)
+	}
+	if len(contents) != 1 || contents[0].Text == nil || *contents[0].Text != "started" {
+		t.Errorf("contents = %+v", contents)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package client
+
+import (
+	"os"
+	"syscall"
+)
+
+// terminate asks the process to exit with SIGTERM, reporting false when the
+// platform has no such signal
+func terminate(p *os.Process) (bool, error) {
+	return true, p.Signal(syscall.SIGTERM)
+}
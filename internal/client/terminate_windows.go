@@ -0,0 +1,11 @@
+//go:build windows
+
+package client
+
+import "os"
+
+// terminate asks the process to exit with SIGTERM, reporting false when the
+// platform has no such signal
+func terminate(p *os.Process) (bool, error) {
+	return false, nil
+}
@@ -0,0 +1,127 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/jsonrpc2"
+)
+
+// spanSet holds the spans of the calls waiting for the server, so that the
+// requests the server sends meanwhile are traced as part of them
+type spanSet struct {
+	mu     sync.Mutex
+	nextID int
+	// spans maps an ID to the context of a span, spans are not always
+	// comparable
+	spans map[int]trace.SpanContext
+}
+
+// add records span until the returned function is called. Spans that are
+// not recorded are ignored, which keeps the no-op tracer free.
+func (s *spanSet) add(span trace.Span) func() {
+	if !span.IsRecording() {
+		return func() {}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.spans == nil {
+		s.spans = make(map[int]trace.SpanContext)
+	}
+	id := s.nextID
+	s.nextID++
+	s.spans[id] = span.SpanContext()
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.spans, id)
+	}
+}
+
+func (s *spanSet) contexts() []trace.SpanContext {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	contexts := make([]trace.SpanContext, 0, len(s.spans))
+	for _, sc := range s.spans {
+		contexts = append(contexts, sc)
+	}
+	return contexts
+}
+
+// startSpan starts the span of a request sent by the server. The server
+// does not say which call the request belongs to: with a single call
+// waiting, such as a tool asking for sampling, the span is its child,
+// otherwise it is linked to all the calls waiting.
+func (d *dispatcher) startSpan(
+	ctx context.Context,
+	req *jsonrpc2.Request,
+) (context.Context, trace.Span) {
+	opts := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("rpc.system", "jsonrpc")),
+		trace.WithAttributes(attribute.String("rpc.method", req.Method)),
+	}
+	switch waiting := d.spans.contexts(); len(waiting) {
+	case 0:
+	case 1:
+		ctx = trace.ContextWithSpanContext(ctx, waiting[0])
+	default:
+		for _, sc := range waiting {
+			opts = append(opts, trace.WithLinks(trace.Link{SpanContext: sc}))
+		}
+	}
+	return d.tracer.Start(ctx, "mcp.client.handle."+req.Method, opts...)
+}
+
+// withTraceContext returns params with the trace context of ctx added to
+// their _meta, as traceparent and tracestate, so that the server can
+// continue the trace. Params that are not an object are left alone.
+func withTraceContext(ctx context.Context, params interface{}) (json.RawMessage, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if bytes.Equal(data, []byte("null")) {
+		fields = make(map[string]json.RawMessage)
+	} else if json.Unmarshal(data, &fields) != nil {
+		return data, nil
+	}
+
+	// Keep the other fields raw, a progress token must not go through a
+	// float64
+	meta := make(map[string]json.RawMessage)
+	if raw, ok := fields["_meta"]; ok {
+		if err := json.Unmarshal(raw, &meta); err != nil || meta == nil {
+			return data, nil
+		}
+	}
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	for key, value := range carrier {
+		if meta[key], err = json.Marshal(value); err != nil {
+			return nil, err
+		}
+	}
+	if fields["_meta"], err = json.Marshal(meta); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// serverAttrs returns the attributes naming the server, once initialized
+func (c *client) serverAttrs() []attribute.KeyValue {
+	info := c.serverInfo.Load()
+	if info == nil {
+		return nil
+	}
+	return []attribute.KeyValue{
+		attribute.String("mcp.server.name", info.ServerInfo.Name),
+		attribute.String("mcp.server.version", info.ServerInfo.Version),
+	}
+}
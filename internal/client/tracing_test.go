@@ -0,0 +1,203 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+	"go.opentelemetry.io/otel/trace/noop"
+	"golang.org/x/exp/jsonrpc2"
+)
+
+// recordingProvider is a tracer provider keeping the spans started, in
+// place of an SDK exporter
+type recordingProvider struct {
+	embedded.TracerProvider
+
+	mu     sync.Mutex
+	nextID byte
+	spans  []*recordedSpan
+}
+
+func (p *recordingProvider) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return recordingTracer{provider: p}
+}
+
+// ended returns the spans called name once ended
+func (p *recordingProvider) ended(name string) []*recordedSpan {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var spans []*recordedSpan
+	for _, s := range p.spans {
+		if s.name == name && s.isEnded() {
+			spans = append(spans, s)
+		}
+	}
+	return spans
+}
+
+type recordingTracer struct {
+	embedded.Tracer
+	provider *recordingProvider
+}
+
+func (t recordingTracer) Start(
+	ctx context.Context,
+	name string,
+	opts ...trace.SpanStartOption,
+) (context.Context, trace.Span) {
+	cfg := trace.NewSpanStartConfig(opts...)
+	parent := trace.SpanContextFromContext(ctx)
+
+	t.provider.mu.Lock()
+	t.provider.nextID++
+	id := t.provider.nextID
+	traceID := parent.TraceID()
+	if !traceID.IsValid() {
+		traceID = trace.TraceID{id}
+	}
+	span := &recordedSpan{
+		name:   name,
+		parent: parent,
+		attrs:  cfg.Attributes(),
+		sc: trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    traceID,
+			SpanID:     trace.SpanID{id},
+			TraceFlags: trace.FlagsSampled,
+		}),
+	}
+	t.provider.spans = append(t.provider.spans, span)
+	t.provider.mu.Unlock()
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+type recordedSpan struct {
+	noop.Span
+
+	name   string
+	sc     trace.SpanContext
+	parent trace.SpanContext
+
+	mu     sync.Mutex
+	attrs  []attribute.KeyValue
+	status codes.Code
+	ended  bool
+}
+
+func (s *recordedSpan) SpanContext() trace.SpanContext { return s.sc }
+func (s *recordedSpan) IsRecording() bool              { return true }
+
+func (s *recordedSpan) SetAttributes(kv ...attribute.KeyValue) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs = append(s.attrs, kv...)
+}
+
+func (s *recordedSpan) SetStatus(code codes.Code, _ string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = code
+}
+
+func (s *recordedSpan) End(...trace.SpanEndOption) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ended = true
+}
+
+func (s *recordedSpan) isEnded() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ended
+}
+
+func (s *recordedSpan) attr(key attribute.Key) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, kv := range s.attrs {
+		if kv.Key == key {
+			return kv.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestTracing(t *testing.T) {
+	s := newFakeServer()
+	s.handle("tools/call", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var p struct {
+			Name string `json:"name"`
+			Meta struct {
+				Traceparent string `json:"traceparent"`
+			} `json:"_meta"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, jsonrpc2.ErrInvalidParams
+		}
+		if p.Name == "broken" {
+			return nil, jsonrpc2.NewError(CodeInternalError, "broken")
+		}
+		if p.Meta.Traceparent == "" {
+			return nil, jsonrpc2.NewError(CodeInvalidParams, "no traceparent")
+		}
+		// The tool asks the host LLM while the call is in flight
+		var result CreateMessageResult
+		if err := s.callClient(ctx, "sampling/createMessage", CreateMessageRequestParams{
+			MaxTokens: 10,
+			Messages:  []SamplingMessage{},
+		}, &result); err != nil {
+			return nil, err
+		}
+		return textResult(result.Model), nil
+	})
+	tp := &recordingProvider{}
+	c := newInitializedClient(t, s, WithOtelTracing(tp), WithSamplingCapability())
+	c.SetSamplingHandler(func(context.Context, CreateMessageRequestParams) (CreateMessageResult, error) {
+		return CreateMessageResult{Model: "test", Role: RoleAssistant, Content: TextContent{Type: "text", Text: "hi"}}, nil
+	})
+	ctx := testContext(t)
+
+	if _, err := c.CallTool(ctx, "sample", nil); err != nil {
+		t.Fatalf("CallTool(sample): %v", err)
+	}
+	calls := tp.ended("mcp.client.tools/call")
+	if len(calls) != 1 {
+		t.Fatalf("got %d tools/call spans, want 1", len(calls))
+	}
+	call := calls[0]
+	if call.attr("rpc.method") != "tools/call" || call.attr("mcp.tool.name") != "sample" {
+		t.Errorf("tools/call span attributes = %v", call.attrs)
+	}
+	if call.status == codes.Error {
+		t.Error("tools/call span has an error status")
+	}
+
+	// The sampling request of the server is traced under the tool call. Its
+	// span ends once the response is written, possibly after the tool result
+	// came back.
+	var handled []*recordedSpan
+	eventually(t, func() bool {
+		handled = tp.ended("mcp.client.handle.sampling/createMessage")
+		return len(handled) > 0
+	}, "the sampling span did not end")
+	if len(handled) != 1 {
+		t.Fatalf("got %d sampling spans, want 1", len(handled))
+	}
+	if handled[0].parent.SpanID() != call.sc.SpanID() {
+		t.Errorf("sampling span parent = %v, want the tools/call span %v",
+			handled[0].parent.SpanID(), call.sc.SpanID())
+	}
+
+	if _, err := c.CallTool(ctx, "broken", nil); err == nil {
+		t.Fatal("CallTool(broken) succeeded")
+	}
+	calls = tp.ended("mcp.client.tools/call")
+	if len(calls) != 2 || calls[1].status != codes.Error {
+		t.Errorf("failed call span status = %v, want an error", calls[len(calls)-1].status)
+	}
+}
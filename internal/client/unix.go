@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
+	"syscall"
+	"time"
+)
+
+// NewUnix creates a client for a server listening on the Unix domain socket
+// at socketPath, speaking newline delimited JSON-RPC like a stdio server.
+// Connecting is bounded by WithConnectTimeout. Close closes the connection.
+// Unix domain sockets are not supported on Windows.
+func NewUnix(
+	ctx context.Context,
+	logger *slog.Logger,
+	socketPath string,
+	opts ...Option,
+) (Client, error) {
+	if err := unixSocketsSupported(); err != nil {
+		return nil, err
+	}
+	o := newOptions(opts)
+	return NewWithTransport(ctx, logger, unixTransport{
+		path:    socketPath,
+		timeout: o.connectTimeout,
+	}, opts...)
+}
+
+// unixTransport connects to a server listening on a Unix domain socket
+type unixTransport struct {
+	path    string
+	timeout time.Duration
+}
+
+func (t unixTransport) Dial(ctx context.Context) (io.ReadWriteCloser, error) {
+	dialer := &net.Dialer{Timeout: t.timeout}
+	conn, err := dialer.DialContext(ctx, "unix", t.path)
+	switch {
+	case err == nil:
+		return conn, nil
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, fmt.Errorf("no server socket at %s: %w", t.path, err)
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return nil, fmt.Errorf("server socket %s refused the connection, is the server running: %w", t.path, err)
+	default:
+		return nil, fmt.Errorf("failed to connect to %s: %w", t.path, err)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package client
+
+// unixSocketsSupported reports an error when the platform cannot connect
+// to Unix domain sockets
+func unixSocketsSupported() error {
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build windows
+
+package client
+
+import "errors"
+
+// unixSocketsSupported reports an error when the platform cannot connect
+// to Unix domain sockets
+func unixSocketsSupported() error {
+	return errors.New("unix domain sockets are not supported on windows, use NewTCP instead")
+}
@@ -0,0 +1,380 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ArgViolation is a tool argument that does not match the input schema of
+// the tool
+type ArgViolation struct {
+	// Path locates the argument, like "filter.tags[2]"
+	Path    string
+	Message string
+}
+
+// ArgValidationError is returned by CallToolValidated when the arguments do
+// not match the input schema of the tool. No request was sent.
+type ArgValidationError struct {
+	Tool       string
+	Violations []ArgViolation
+}
+
+func (e *ArgValidationError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.Path + ": " + v.Message
+	}
+	return fmt.Sprintf("invalid arguments for tool %q: %s", e.Tool, strings.Join(msgs, "; "))
+}
+
+// toolCache holds the tools listed by the server, filled on first use and
+// cleared when the server notifies that the list changed or after ttl
+type toolCache struct {
+	mu    sync.Mutex
+	tools map[string]Tool
+	// list holds the same tools in the order of the server
+	list    []Tool
+	fetched time.Time
+	// ttl bounds how long the tools are cached, until they change when zero
+	ttl time.Duration
+	// generation changes on every invalidation, so that a list fetched
+	// across a change is not cached
+	generation int
+}
+
+func (t *toolCache) invalidate() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tools = nil
+	t.list = nil
+	t.generation++
+}
+
+// get returns the cached tools, nil once they must be listed again
+func (t *toolCache) get() ([]Tool, map[string]Tool, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tools != nil && t.ttl > 0 && time.Since(t.fetched) >= t.ttl {
+		t.tools = nil
+		t.list = nil
+	}
+	return t.list, t.tools, t.generation
+}
+
+// cachedTools returns the tools of the server, listing all their pages
+// when they are not cached
+func (c *client) cachedTools(ctx context.Context) ([]Tool, map[string]Tool, error) {
+	list, tools, generation := c.tools.get()
+	if tools != nil {
+		return list, tools, nil
+	}
+
+	list, err := listAll(ctx, c.listTools)
+	if err != nil {
+		return nil, nil, err
+	}
+	tools = make(map[string]Tool, len(list))
+	for _, tool := range list {
+		tools[tool.Name] = tool
+	}
+
+	c.tools.mu.Lock()
+	if c.tools.generation == generation {
+		c.tools.list = list
+		c.tools.tools = tools
+		c.tools.fetched = time.Now()
+	}
+	c.tools.mu.Unlock()
+	return list, tools, nil
+}
+
+// lookupTool returns the tool called name, listing the tools of the server
+// when they are not cached
+func (c *client) lookupTool(ctx context.Context, name string) (Tool, bool, error) {
+	_, tools, err := c.cachedTools(ctx)
+	if err != nil {
+		return Tool{}, false, err
+	}
+	tool, ok := tools[name]
+	return tool, ok, nil
+}
+
+// Tool returns the tool called name from the cached tools list, filled by
+// ListTools with WithToolCache and by CallToolValidated. The server is not
+// asked, a tool that is not cached is not found.
+func (c *client) Tool(name string) (*Tool, bool) {
+	_, tools, _ := c.tools.get()
+	tool, ok := tools[name]
+	if !ok {
+		return nil, false
+	}
+	return &tool, true
+}
+
+// CallToolValidated checks args against the input schema of the tool before
+// calling it. The schema comes from the tools list, fetched on first use
+// and again after the server notifies that it changed. Required
+// properties, types, enums, nested objects and array items are checked,
+// other schema features such as oneOf or $ref are ignored. A mismatch
+// fails with *ArgValidationError without sending the call.
+func (c *client) CallToolValidated(
+	ctx context.Context,
+	name string,
+	args interface{},
+	opts ...CallOption,
+) (*CallToolResult, error) {
+	if err := c.checkInitialized(ctx); err != nil {
+		return nil, err
+	}
+	tool, ok, err := c.lookupTool(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tools: %w", err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown tool %q", name)
+	}
+
+	arguments, err := toolArguments(args)
+	if err != nil {
+		return nil, fmt.Errorf("invalid arguments for tool %q: %w", name, err)
+	}
+	violations, err := validateArguments(tool.InputSchema, arguments)
+	if err != nil {
+		return nil, fmt.Errorf("invalid arguments for tool %q: %w", name, err)
+	}
+	if len(violations) > 0 {
+		return nil, &ArgValidationError{Tool: name, Violations: violations}
+	}
+
+	return c.CallTool(ctx, name, arguments, opts...)
+}
+
+// validateArguments checks the arguments of a tool call against its input
+// schema
+func validateArguments(
+	schema ToolInputSchema,
+	arguments CallToolRequestParamsArguments,
+) ([]ArgViolation, error) {
+	// Go through JSON so that the arguments and the schema hold the same
+	// kind of values whatever the caller passed
+	value, err := normalizeJSON(arguments)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		value = map[string]interface{}{}
+	}
+
+	properties := make(map[string]interface{}, len(schema.Properties))
+	for name, prop := range schema.Properties {
+		properties[name] = map[string]interface{}(prop)
+	}
+	required := make([]interface{}, len(schema.Required))
+	for i, name := range schema.Required {
+		required[i] = name
+	}
+
+	var v validator
+	v.validate("", map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}, value)
+	return v.violations, nil
+}
+
+func normalizeJSON(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+type validator struct {
+	violations []ArgViolation
+}
+
+func (v *validator) fail(path, format string, args ...interface{}) {
+	if path == "" {
+		path = "(arguments)"
+	}
+	v.violations = append(v.violations, ArgViolation{
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// validate checks value against the subset of JSON schema the client
+// understands
+func (v *validator) validate(path string, schema map[string]interface{}, value interface{}) {
+	if types := schemaTypes(schema["type"]); len(types) > 0 {
+		matched := false
+		for _, t := range types {
+			if ok, known := hasType(value, t); ok || !known {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.fail(path, "expected %s, got %s", strings.Join(types, " or "), jsonType(value))
+			return
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok && !inEnum(value, enum) {
+		v.fail(path, "must be one of %s", formatEnum(enum))
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, name := range required {
+				name, ok := name.(string)
+				if !ok {
+					continue
+				}
+				if _, ok := value[name]; !ok {
+					v.fail(joinPath(path, name), "missing required property")
+				}
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if prop, ok := properties[name].(map[string]interface{}); ok {
+				v.validate(joinPath(path, name), prop, value[name])
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range value {
+				v.validate(fmt.Sprintf("%s[%d]", path, i), items, item)
+			}
+		}
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// schemaTypes returns the types allowed by the "type" keyword, given as a
+// string or a list of strings
+func schemaTypes(t interface{}) []string {
+	switch t := t.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		var types []string
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+// hasType reports whether value is of the JSON schema type t, and whether t
+// is a type the validator knows
+func hasType(value interface{}, t string) (ok, known bool) {
+	switch t {
+	case "string":
+		_, ok = value.(string)
+	case "number":
+		_, ok = value.(json.Number)
+	case "integer":
+		if n, isNumber := value.(json.Number); isNumber {
+			f, err := n.Float64()
+			ok = err == nil && f == math.Trunc(f)
+		}
+	case "boolean":
+		_, ok = value.(bool)
+	case "object":
+		_, ok = value.(map[string]interface{})
+	case "array":
+		_, ok = value.([]interface{})
+	case "null":
+		ok = value == nil
+	default:
+		return false, false
+	}
+	return ok, true
+}
+
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// inEnum compares the JSON encodings of value and the enum members, so that
+// 1 and 1.0 or differently typed maps are compared by value
+func inEnum(value interface{}, enum []interface{}) bool {
+	want, err := canonicalJSON(value)
+	if err != nil {
+		return true
+	}
+	for _, member := range enum {
+		if got, err := canonicalJSON(member); err == nil && got == want {
+			return true
+		}
+	}
+	return false
+}
+
+func canonicalJSON(value interface{}) (string, error) {
+	if n, ok := value.(json.Number); ok {
+		f, err := n.Float64()
+		if err != nil {
+			return "", err
+		}
+		value = f
+	}
+	data, err := json.Marshal(value)
+	return string(data), err
+}
+
+func formatEnum(enum []interface{}) string {
+	members := make([]string, len(enum))
+	for i, member := range enum {
+		data, _ := json.Marshal(member)
+		members[i] = string(data)
+	}
+	return "[" + strings.Join(members, ", ") + "]"
+}
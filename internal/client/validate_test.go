@@ -0,0 +1,166 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var searchSchema = ToolInputSchema{
+	Type: "object",
+	Properties: ToolInputSchemaProperties{
+		"query": {"type": "string"},
+		"limit": {"type": "integer"},
+		"score": {"type": "number"},
+		"exact": {"type": "boolean"},
+		"order": {"type": "string", "enum": []interface{}{"asc", "desc"}},
+		"page":  {"type": []interface{}{"integer", "null"}},
+		"filter": {
+			"type":     "object",
+			"required": []interface{}{"field"},
+			"properties": map[string]interface{}{
+				"field": map[string]interface{}{"type": "string"},
+				"tags": map[string]interface{}{
+					"type":  "array",
+					"items": map[string]interface{}{"type": "string"},
+				},
+			},
+		},
+		"either": {"oneOf": []interface{}{
+			map[string]interface{}{"type": "string"},
+			map[string]interface{}{"type": "integer"},
+		}},
+		"ref": {"$ref": "#/definitions/thing"},
+	},
+	Required: []string{"query"},
+}
+
+func TestValidateArguments(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		args CallToolRequestParamsArguments
+		want []ArgViolation
+	}{
+		{name: "required only", args: map[string]interface{}{"query": "go"}},
+		{
+			name: "all valid",
+			args: map[string]interface{}{
+				"query":  "go",
+				"limit":  10,
+				"score":  0.5,
+				"exact":  true,
+				"order":  "desc",
+				"page":   nil,
+				"filter": map[string]interface{}{"field": "name", "tags": []string{"a", "b"}},
+			},
+		},
+		{name: "integral float is an integer", args: map[string]interface{}{"query": "go", "limit": 10.0}},
+		{name: "unknown property", args: map[string]interface{}{"query": "go", "other": 1}},
+		{name: "oneOf and $ref ignored", args: map[string]interface{}{"query": "go", "either": true, "ref": 1}},
+		{
+			name: "missing required",
+			args: map[string]interface{}{"limit": 1},
+			want: []ArgViolation{{Path: "query", Message: "missing required property"}},
+		},
+		{
+			name: "no arguments",
+			want: []ArgViolation{{Path: "query", Message: "missing required property"}},
+		},
+		{
+			name: "wrong types",
+			args: map[string]interface{}{"query": 1, "limit": "ten", "score": "high", "exact": "yes"},
+			want: []ArgViolation{
+				{Path: "exact", Message: "expected boolean, got string"},
+				{Path: "limit", Message: "expected integer, got string"},
+				{Path: "query", Message: "expected string, got number"},
+				{Path: "score", Message: "expected number, got string"},
+			},
+		},
+		{
+			name: "fractional integer",
+			args: map[string]interface{}{"query": "go", "limit": 1.5},
+			want: []ArgViolation{{Path: "limit", Message: "expected integer, got number"}},
+		},
+		{
+			name: "type list",
+			args: map[string]interface{}{"query": "go", "page": "1"},
+			want: []ArgViolation{{Path: "page", Message: "expected integer or null, got string"}},
+		},
+		{
+			name: "not in enum",
+			args: map[string]interface{}{"query": "go", "order": "up"},
+			want: []ArgViolation{{Path: "order", Message: `must be one of ["asc", "desc"]`}},
+		},
+		{
+			name: "nested",
+			args: map[string]interface{}{
+				"query":  "go",
+				"filter": map[string]interface{}{"tags": []interface{}{"a", 2}},
+			},
+			want: []ArgViolation{
+				{Path: "filter.field", Message: "missing required property"},
+				{Path: "filter.tags[1]", Message: "expected string, got number"},
+			},
+		},
+		{
+			name: "nested wrong type",
+			args: map[string]interface{}{"query": "go", "filter": []interface{}{}},
+			want: []ArgViolation{{Path: "filter", Message: "expected object, got array"}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := validateArguments(searchSchema, tt.args)
+			if err != nil {
+				t.Fatalf("validateArguments: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("violations = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCallToolValidated(t *testing.T) {
+	s := newFakeServer()
+	s.handle("tools/list", func(context.Context, json.RawMessage) (interface{}, error) {
+		return ListToolsResult{Tools: []Tool{{Name: "search", InputSchema: searchSchema}}}, nil
+	})
+	s.handle("tools/call", func(context.Context, json.RawMessage) (interface{}, error) {
+		return textResult("found"), nil
+	})
+	c := newInitializedClient(t, s)
+	ctx := testContext(t)
+
+	_, err := c.CallToolValidated(ctx, "search", map[string]interface{}{"query": 1, "order": "up"})
+	var verr *ArgValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("CallToolValidated error = %v, want *ArgValidationError", err)
+	}
+	if verr.Tool != "search" || len(verr.Violations) != 2 {
+		t.Errorf("error = %+v", verr)
+	}
+	want := `invalid arguments for tool "search": order: must be one of ["asc", "desc"]; query: expected string, got number`
+	if err.Error() != want {
+		t.Errorf("error = %q, want %q", err, want)
+	}
+	if calls := s.requests("tools/call"); len(calls) != 0 {
+		t.Errorf("sent %d tools/call requests for invalid arguments", len(calls))
+	}
+
+	result, err := c.CallToolValidated(ctx, "search", map[string]interface{}{"query": "go"})
+	if err != nil {
+		t.Fatalf("CallToolValidated: %v", err)
+	}
+	if len(result.Content) != 1 {
+		t.Errorf("result = %+v", result)
+	}
+
+	if _, err := c.CallToolValidated(ctx, "missing", nil); err == nil || errors.As(err, &verr) {
+		t.Errorf("CallToolValidated(missing) = %v, want an unknown tool error", err)
+	}
+	if n := len(s.requests("tools/list")); n != 1 {
+		t.Errorf("listed tools %d times, want once", n)
+	}
+}
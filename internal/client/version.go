@@ -0,0 +1,48 @@
+package client
+
+import (
+	"fmt"
+	"slices"
+)
+
+// LatestProtocolVersion is the protocol version the client asks for
+const LatestProtocolVersion = "2025-03-26"
+
+// SupportedProtocolVersions lists the protocol versions the client can
+// speak, newest first
+var SupportedProtocolVersions = []string{
+	"2025-03-26",
+	"2024-11-05",
+}
+
+// ErrUnsupportedProtocolVersion is returned by Initialize when the server
+// answers with a protocol version the client does not support
+type ErrUnsupportedProtocolVersion struct {
+	// Requested is the version sent by the client
+	Requested string
+	// Got is the version the server answered with
+	Got string
+	// Supported lists the versions the client would have accepted
+	Supported []string
+}
+
+func (e *ErrUnsupportedProtocolVersion) Error() string {
+	return fmt.Sprintf(
+		"unsupported protocol version %q (requested %q, supported %v)",
+		e.Got, e.Requested, e.Supported,
+	)
+}
+
+// negotiateVersion checks the version the server answered to an initialize
+// request sent with requested. The spec lets the server answer with another
+// version it supports, which is fine as long as it is in supported too.
+func negotiateVersion(requested, got string, supported []string) error {
+	if got == requested || slices.Contains(supported, got) {
+		return nil
+	}
+	return &ErrUnsupportedProtocolVersion{
+		Requested: requested,
+		Got:       got,
+		Supported: supported,
+	}
+}
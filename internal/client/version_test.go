@@ -0,0 +1,103 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestNegotiateProtocolVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		answer  string
+		wantErr bool
+	}{
+		{name: "matching", answer: LatestProtocolVersion},
+		{name: "downgradeable", answer: "2024-11-05"},
+		{name: "incompatible", answer: "2023-01-01", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFakeServer()
+			s.version = tt.answer
+			c := newTestClient(t, s)
+
+			info, err := c.Initialize(testContext(t))
+			var params InitializeRequestParams
+			if err := json.Unmarshal(s.waitRequest(t, "initialize", 1).Params, &params); err != nil {
+				t.Fatal(err)
+			}
+			if params.ProtocolVersion != LatestProtocolVersion {
+				t.Errorf("requested %q, want %q", params.ProtocolVersion, LatestProtocolVersion)
+			}
+
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("Initialize: %v", err)
+				}
+				if info.ProtocolVersion != tt.answer {
+					t.Errorf("negotiated %q, want %q", info.ProtocolVersion, tt.answer)
+				}
+				return
+			}
+			var unsupported *ErrUnsupportedProtocolVersion
+			if !errors.As(err, &unsupported) {
+				t.Fatalf("Initialize = %v, want *ErrUnsupportedProtocolVersion", err)
+			}
+			if unsupported.Requested != LatestProtocolVersion || unsupported.Got != tt.answer {
+				t.Errorf("error = %+v", unsupported)
+			}
+			if c.Healthy() {
+				t.Error("client initialized with an unsupported version")
+			}
+		})
+	}
+}
+
+func TestWithProtocolVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		want    string
+		wantErr bool
+	}{
+		{name: "default", want: LatestProtocolVersion},
+		{name: "older", opts: []Option{WithProtocolVersion("2024-11-05")}, want: "2024-11-05"},
+		{
+			name: "extended",
+			opts: []Option{
+				WithSupportedProtocolVersions("2099-01-01", LatestProtocolVersion),
+				WithProtocolVersion("2099-01-01"),
+			},
+			want: "2099-01-01",
+		},
+		{name: "unknown", opts: []Option{WithProtocolVersion("2099-01-01")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFakeServer()
+			c := newTestClient(t, s, tt.opts...)
+
+			_, err := c.Initialize(testContext(t))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Initialize succeeded with an unsupported version")
+				}
+				if reqs := s.requests("initialize"); len(reqs) != 0 {
+					t.Errorf("initialize sent with an unsupported version")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Initialize: %v", err)
+			}
+			var params InitializeRequestParams
+			if err := json.Unmarshal(s.waitRequest(t, "initialize", 1).Params, &params); err != nil {
+				t.Fatal(err)
+			}
+			if params.ProtocolVersion != tt.want {
+				t.Errorf("protocolVersion sent = %q, want %q", params.ProtocolVersion, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/coder/websocket"
+	"golang.org/x/exp/jsonrpc2"
+)
+
+const (
+	// wsReadLimit bounds the size of a message read from a WebSocket server
+	wsReadLimit = 32 << 20
+
+	// defaultWSPingInterval is how often WebSocket connections are pinged
+	defaultWSPingInterval = 30 * time.Second
+)
+
+// NewWS creates a client for a server reachable over WebSocket at url, a
+// ws:// or wss:// URL. Every JSON-RPC message is sent as one text message,
+// so WithFramer does not apply. Connecting is bounded by
+// WithConnectTimeout, and WithTLSConfig sets the TLS config of wss://
+// connections. WithHTTPHeader adds headers to the handshake, such as for
+// authentication. The connection is pinged every WithWSPingInterval, and
+// Close ends it with a close handshake.
+func NewWS(
+	ctx context.Context,
+	logger *slog.Logger,
+	url string,
+	opts ...Option,
+) (Client, error) {
+	o := newOptions(opts)
+	return NewWithTransport(ctx, logger, wsTransport{
+		url:          url,
+		timeout:      o.connectTimeout,
+		tlsConfig:    o.tlsConfig,
+		client:       o.client,
+		header:       o.httpHeader,
+		pingInterval: o.wsPingInterval,
+	}, opts...)
+}
+
+// NewWebSocket is another name for NewWS
+func NewWebSocket(
+	ctx context.Context,
+	logger *slog.Logger,
+	url string,
+	opts ...Option,
+) (Client, error) {
+	return NewWS(ctx, logger, url, opts...)
+}
+
+// wsTransport connects to a WebSocket server
+type wsTransport struct {
+	url          string
+	timeout      time.Duration
+	tlsConfig    *tls.Config
+	client       *http.Client
+	header       http.Header
+	pingInterval time.Duration
+}
+
+func (t wsTransport) Dial(ctx context.Context) (io.ReadWriteCloser, error) {
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+
+	dialOpts := &websocket.DialOptions{
+		HTTPClient: t.client,
+		HTTPHeader: t.header.Clone(),
+	}
+	if t.client == nil && t.tlsConfig != nil {
+		dialOpts.HTTPClient = &http.Client{
+			Transport: &http.Transport{TLSClientConfig: t.tlsConfig},
+		}
+	}
+	conn, _, err := websocket.Dial(ctx, t.url, dialOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", t.url, err)
+	}
+	conn.SetReadLimit(wsReadLimit)
+
+	pingCtx, stopPing := context.WithCancel(context.Background())
+	s := &wsStream{
+		Conn:     websocket.NetConn(context.Background(), conn, websocket.MessageText),
+		ws:       conn,
+		stopPing: stopPing,
+	}
+	if t.pingInterval > 0 {
+		go s.ping(pingCtx, t.pingInterval)
+	}
+	return s, nil
+}
+
+func (wsTransport) framer() jsonrpc2.Framer {
+	return wsFramer{}
+}
+
+// wsStream is the stream of a WebSocket connection. The wsFramer exchanges
+// messages with ws, the net.Conn view only serves to close it.
+type wsStream struct {
+	net.Conn
+	ws       *websocket.Conn
+	stopPing context.CancelFunc
+}
+
+// ping pings the server every interval until the stream is closed. A ping
+// not answered within the interval drops the connection, which fails the
+// pending reads and closes the client.
+func (s *wsStream) ping(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		pingCtx, cancel := context.WithTimeout(ctx, interval)
+		err := s.ws.Ping(pingCtx)
+		cancel()
+		if err != nil && ctx.Err() == nil {
+			_ = s.ws.CloseNow()
+			return
+		}
+	}
+}
+
+// Close stops the pings and closes the connection with a close handshake
+func (s *wsStream) Close() error {
+	s.stopPing()
+	return s.Conn.Close()
+}
+
+// wsFramer reads and writes one JSON-RPC message, or batch, per WebSocket
+// message. It only works on a wsStream.
+type wsFramer struct{}
+
+func (wsFramer) Reader(r io.Reader) jsonrpc2.Reader {
+	return &wsReader{ws: r.(*wsStream).ws}
+}
+
+func (wsFramer) Writer(w io.Writer) jsonrpc2.Writer {
+	return &wsWriter{ws: w.(*wsStream).ws}
+}
+
+type wsReader struct {
+	ws *websocket.Conn
+	// pending holds the messages left from a batch
+	pending []jsonrpc2.Message
+}
+
+func (r *wsReader) Read(ctx context.Context) (jsonrpc2.Message, int64, error) {
+	if msg, ok := popPending(&r.pending); ok {
+		return msg, 0, nil
+	}
+
+	_, data, err := r.ws.Read(ctx)
+	if err != nil {
+		// The server closing the connection ends the stream cleanly
+		switch websocket.CloseStatus(err) {
+		case websocket.StatusNormalClosure, websocket.StatusGoingAway:
+			return nil, 0, io.EOF
+		}
+		if errors.Is(err, net.ErrClosed) {
+			return nil, 0, io.EOF
+		}
+		return nil, 0, fmt.Errorf("failed to read message: %w", err)
+	}
+
+	msgs, err := decodeFrame(data)
+	if err != nil {
+		return nil, int64(len(data)), err
+	}
+	r.pending = msgs[1:]
+	return msgs[0], int64(len(data)), nil
+}
+
+type wsWriter struct {
+	ws *websocket.Conn
+}
+
+func (w *wsWriter) Write(ctx context.Context, msg jsonrpc2.Message) (int64, error) {
+	data, err := jsonrpc2.EncodeMessage(msg)
+	if err != nil {
+		return 0, fmt.Errorf("marshaling message: %w", err)
+	}
+	if err := w.ws.Write(ctx, websocket.MessageText, data); err != nil {
+		return 0, err
+	}
+	return int64(len(data)), nil
+}
+
+// writeBatch writes msgs as a single JSON array in one message
+func (w *wsWriter) writeBatch(ctx context.Context, msgs []jsonrpc2.Message) (int64, error) {
+	data, err := encodeBatch(msgs)
+	if err != nil {
+		return 0, err
+	}
+	if err := w.ws.Write(ctx, websocket.MessageText, data); err != nil {
+		return 0, err
+	}
+	return int64(len(data)), nil
+}
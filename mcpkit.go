@@ -2,21 +2,305 @@ package mcpkit
 
 import (
 	"context"
+	"io"
 	"log/slog"
 
 	"github.com/y0ug/mcpkit/internal/client"
 )
 
 type (
-	Client = client.Client
-	Tool   = client.Tool
+	Client           = client.Client
+	ServerInfo       = client.ServerInfo
+	ClientOption     = client.Option
+	CallOption       = client.CallOption
+	ReconnectPolicy  = client.ReconnectPolicy
+	RetryPolicy      = client.RetryPolicy
+	Tool             = client.Tool
+	ToolAnnotations  = client.ToolAnnotations
+	Resource         = client.Resource
+	ResourceTemplate = client.ResourceTemplate
+	ResourceContent  = client.ResourceContent
+	Prompt           = client.Prompt
+	PromptArgument   = client.PromptArgument
+	GetPromptResult  = client.GetPromptResult
+	Root             = client.Root
+	CallToolResult   = client.CallToolResult
+	Transport        = client.Transport
+	CompletionRef    = client.CompletionRef
+	CompletionResult = client.CompletionResult
+	TextContent      = client.TextContent
+	ImageContent     = client.ImageContent
+	EmbeddedResource = client.EmbeddedResource
+
+	NotificationHandler        = client.NotificationHandler
+	SamplingHandler            = client.SamplingHandler
+	CreateMessageRequestParams = client.CreateMessageRequestParams
+	CreateMessageResult        = client.CreateMessageResult
+	ErrResourceNotFound        = client.ErrResourceNotFound
+	RPCError                   = client.RPCError
+	BatchRequest               = client.BatchRequest
+	BatchResponse              = client.BatchResponse
+	ErrRequestTimeout          = client.ErrRequestTimeout
+	ErrSessionExpired          = client.ErrSessionExpired
+	Collector                  = client.Collector
+	MemoryCollector            = client.MemoryCollector
+	MethodStats                = client.MethodStats
+	ConnectionStats            = client.ConnectionStats
+	ExpvarMetrics              = client.ExpvarMetrics
+	Router                     = client.Router
+	RouterOption               = client.RouterOption
+	ConflictPolicy             = client.ConflictPolicy
+	ErrNameConflict            = client.ErrNameConflict
+	FatalServerError           = client.FatalServerError
+	ErrCapabilityNotSupported  = client.ErrCapabilityNotSupported
+	ToolError                  = client.ToolError
+	ArgValidationError         = client.ArgValidationError
+	ArgViolation               = client.ArgViolation
+
+	ErrUnsupportedProtocolVersion = client.ErrUnsupportedProtocolVersion
+
+	LoggingLevel               = client.LoggingLevel
+	LoggingMessageNotification = client.LoggingMessageNotification
+)
+
+const (
+	LoggingLevelDebug     = client.LoggingLevelDebug
+	LoggingLevelInfo      = client.LoggingLevelInfo
+	LoggingLevelNotice    = client.LoggingLevelNotice
+	LoggingLevelWarning   = client.LoggingLevelWarning
+	LoggingLevelError     = client.LoggingLevelError
+	LoggingLevelCritical  = client.LoggingLevelCritical
+	LoggingLevelAlert     = client.LoggingLevelAlert
+	LoggingLevelEmergency = client.LoggingLevelEmergency
+)
+
+const (
+	ConflictError = client.ConflictError
+	ConflictFirst = client.ConflictFirst
+	ConflictLast  = client.ConflictLast
+)
+
+const (
+	CodeParseError       = client.CodeParseError
+	CodeInvalidRequest   = client.CodeInvalidRequest
+	CodeMethodNotFound   = client.CodeMethodNotFound
+	CodeInvalidParams    = client.CodeInvalidParams
+	CodeInternalError    = client.CodeInternalError
+	CodeResourceNotFound = client.CodeResourceNotFound
+)
+
+var (
+	WithOtelTracing        = client.WithOtelTracing
+	NewInMemoryTransport   = client.NewInMemoryTransport
+	WithMetrics            = client.WithMetrics
+	WithBatchTimeout       = client.WithBatchTimeout
+	NewRouter              = client.NewRouter
+	RouterWithPrefix       = client.RouterWithPrefix
+	RouterWithScheme       = client.RouterWithScheme
+	NewMemoryCollector     = client.NewMemoryCollector
+	NewExpvarMetrics       = client.NewExpvarMetrics
+	WithStderrWriter       = client.WithStderrWriter
+	WithStderrHandler      = client.WithStderrHandler
+	WithStderrInErrors     = client.WithStderrInErrors
+	WithFrameLogging       = client.WithFrameLogging
+	WithWireLog            = client.WithWireLog
+	WithWireLogLimit       = client.WithWireLogLimit
+	WithWireLogRedact      = client.WithWireLogRedact
+	WithClientInfo         = client.WithClientInfo
+	WithFramer             = client.WithFramer
+	NewLineRawFramer       = client.NewLineRawFramer
+	NewHeaderFramer        = client.NewHeaderFramer
+	NewAutoFramer          = client.NewAutoFramer
+	NewRecordingFramer     = client.NewRecordingFramer
+	NewReplayFramer        = client.NewReplayFramer
+	WithSamplingCapability = client.WithSamplingCapability
+	WithRootsCapability    = client.WithRootsCapability
+	WithEnv                = client.WithEnv
+	WithExtraEnv           = client.WithExtraEnv
+	WithInheritEnv         = client.WithInheritEnv
+	WithWorkingDir         = client.WithWorkingDir
+	WithCmdCustomizer      = client.WithCmdCustomizer
+	WithRequestTimeout     = client.WithRequestTimeout
+	WithToolTimeout        = client.WithToolTimeout
+	WithResourceTimeout    = client.WithResourceTimeout
+	CallWithTimeout        = client.CallWithTimeout
+	CallWithPriority       = client.CallWithPriority
+	DefaultReconnectPolicy = client.DefaultReconnectPolicy
+	ErrReconnectFailed     = client.ErrReconnectFailed
+	WithRetry              = client.WithRetry
+	WithAutoInitialize     = client.WithAutoInitialize
+	WithLazyStart          = client.WithLazyStart
+	WithToolCache          = client.WithToolCache
+	DefaultRetryPolicy     = client.DefaultRetryPolicy
+	DefaultRetryable       = client.DefaultRetryable
+	ErrNotSent             = client.ErrNotSent
+	ErrServerRestarted     = client.ErrServerRestarted
+	ErrClientClosed        = client.ErrClientClosed
+	ErrParse               = client.ErrParse
+	ErrInvalidRequest      = client.ErrInvalidRequest
+	ErrMethodNotFound      = client.ErrMethodNotFound
+	ErrInvalidParams       = client.ErrInvalidParams
+	ErrInternal            = client.ErrInternal
+	WithShutdownTimeout    = client.WithShutdownTimeout
+	WithAutoRestart        = client.WithAutoRestart
+	WithKeepalive          = client.WithKeepalive
+	WithKeepaliveFailures  = client.WithKeepaliveFailures
+	WithOnUnhealthy        = client.WithOnUnhealthy
+	ExpandTemplate         = client.ExpandTemplate
+	WithConnectTimeout     = client.WithConnectTimeout
+	WithTLSConfig          = client.WithTLSConfig
+	PromptRef              = client.PromptRef
+	WithHTTPClient         = client.WithHTTPClient
+	WithHTTPHeader         = client.WithHTTPHeader
+	WithWSPingInterval     = client.WithWSPingInterval
+	ResourceRef            = client.ResourceRef
+
+	WithSupportedProtocolVersions = client.WithSupportedProtocolVersions
+	WithProtocolVersion           = client.WithProtocolVersion
+	RouterWithConflictPolicy      = client.RouterWithConflictPolicy
+	WithStructuredStderrParsing   = client.WithStructuredStderrParsing
+)
+
+const LatestProtocolVersion = client.LatestProtocolVersion
+
+const (
+	PriorityDefault = client.PriorityDefault
+	PriorityHigh    = client.PriorityHigh
 )
 
 func NewClient(
 	ctx context.Context,
 	logger *slog.Logger,
 	serverCmd string,
-	args ...string,
+	args []string,
+	opts ...ClientOption,
 ) (Client, error) {
-	return client.New(ctx, logger, serverCmd, args...)
+	return client.New(ctx, logger, serverCmd, args, opts...)
+}
+
+// NewClientAndInitialize starts the server, runs the initialize handshake and
+// returns the ready client. The server is shut down if initialize fails.
+func NewClientAndInitialize(
+	ctx context.Context,
+	logger *slog.Logger,
+	serverCmd string,
+	args []string,
+	opts ...ClientOption,
+) (Client, *ServerInfo, error) {
+	return client.NewAndInitialize(ctx, logger, serverCmd, args, opts...)
+}
+
+// NewResilientClient creates a client that respawns the server command and
+// re-initializes it whenever the process crashes
+func NewResilientClient(
+	ctx context.Context,
+	logger *slog.Logger,
+	serverCmd string,
+	args []string,
+	policy ReconnectPolicy,
+	opts ...ClientOption,
+) (Client, error) {
+	return client.NewResilient(ctx, logger, serverCmd, args, policy, opts...)
+}
+
+// NewClientFromStream creates a client for a server already running at the
+// other end of rwc, such as a net.Conn or a pipe to an in-process server.
+// Close closes rwc.
+func NewClientFromStream(
+	ctx context.Context,
+	logger *slog.Logger,
+	rwc io.ReadWriteCloser,
+	opts ...ClientOption,
+) (Client, error) {
+	return client.NewFromStream(ctx, logger, rwc, opts...)
+}
+
+// NewTCPClient creates a client for a server listening on the TCP address
+// addr, speaking newline delimited JSON-RPC. Pass WithTLSConfig to connect
+// over TLS.
+func NewTCPClient(
+	ctx context.Context,
+	logger *slog.Logger,
+	addr string,
+	opts ...ClientOption,
+) (Client, error) {
+	return client.NewTCP(ctx, logger, addr, opts...)
+}
+
+// NewClientFromFDs creates a client for a server process started by someone
+// else, given the file descriptors of its stdin and stdout
+func NewClientFromFDs(
+	ctx context.Context,
+	logger *slog.Logger,
+	stdinFD, stdoutFD uintptr,
+	opts ...ClientOption,
+) (Client, error) {
+	return client.NewFromFDs(ctx, logger, stdinFD, stdoutFD, opts...)
+}
+
+// NewUnixClient creates a client for a server listening on the Unix domain
+// socket at socketPath, speaking newline delimited JSON-RPC
+func NewUnixClient(
+	ctx context.Context,
+	logger *slog.Logger,
+	socketPath string,
+	opts ...ClientOption,
+) (Client, error) {
+	return client.NewUnix(ctx, logger, socketPath, opts...)
+}
+
+// NewSSEClient creates a client for a server using the HTTP+SSE transport
+// at baseURL
+func NewSSEClient(
+	ctx context.Context,
+	logger *slog.Logger,
+	baseURL string,
+	opts ...ClientOption,
+) (Client, error) {
+	return client.NewSSE(ctx, logger, baseURL, opts...)
+}
+
+// NewStreamableHTTPClient creates a client for a server using the
+// Streamable HTTP transport at url
+func NewStreamableHTTPClient(
+	ctx context.Context,
+	logger *slog.Logger,
+	url string,
+	opts ...ClientOption,
+) (Client, error) {
+	return client.NewStreamableHTTP(ctx, logger, url, opts...)
+}
+
+// NewWSClient creates a client for a server reachable over WebSocket at
+// wsURL, a ws:// or wss:// URL, with one JSON-RPC message per text message
+func NewWSClient(
+	ctx context.Context,
+	logger *slog.Logger,
+	wsURL string,
+	opts ...ClientOption,
+) (Client, error) {
+	return client.NewWS(ctx, logger, wsURL, opts...)
+}
+
+// NewClientWithTransport creates a client for a server already running,
+// reached through t
+func NewClientWithTransport(
+	ctx context.Context,
+	logger *slog.Logger,
+	t Transport,
+	opts ...ClientOption,
+) (Client, error) {
+	return client.NewWithTransport(ctx, logger, t, opts...)
+}
+
+// CallToolTyped calls the tool and decodes its text content, concatenated,
+// as JSON into a T. A result with isError set is returned as *ToolError.
+func CallToolTyped[T any](
+	ctx context.Context,
+	c Client,
+	name string,
+	args interface{},
+	opts ...CallOption,
+) (T, error) {
+	return client.CallToolTyped[T](ctx, c, name, args, opts...)
 }